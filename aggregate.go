@@ -0,0 +1,277 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Aggregation stage constants
+const (
+	MATCH  = "$match"
+	LOOKUP = "$lookup"
+	LIMIT  = "$limit"
+)
+
+// Aggregate - run aggregation pipeline over documents in collection
+//
+// Supported stages are $match, $limit and $lookup.  $lookup joins documents
+// from another collection either by equality of localField and foreignField
+// or by running a sub-pipeline, in which case values declared in let can be
+// referenced from the sub-pipeline as "$$name".  All collections used by the
+// pipeline are read as of the same point in time.
+func (m *MemJ) Aggregate(collection string, pipeline []interface{}) ([]map[string]interface{}, error) {
+	return m.AggregateCtx(context.Background(), collection, pipeline)
+}
+
+// AggregateCtx - like Aggregate but takes context
+func (m *MemJ) AggregateCtx(ctx context.Context, collection string, pipeline []interface{}) (results []map[string]interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("Aggregate", collection, start, len(results), err) }()
+
+	collections := map[string]bool{collection: true}
+	err = m.pipelineCollections(pipeline, collections)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := m.readCollections(ctx, collections)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err = m.runPipeline(ctx, versions, versions[collection].all(), pipeline)
+	return m.readDocuments(results), err
+}
+
+// runPipeline - run stages over documents, collections holds versions of
+// collections used by $lookup
+func (m *MemJ) runPipeline(ctx context.Context, collections map[string]*collection, documents []map[string]interface{}, pipeline []interface{}) ([]map[string]interface{}, error) {
+	for _, stage := range pipeline {
+		op, arg, err := m.parseStage(stage)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case MATCH:
+			query, ok := arg.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("$match stage expects a query")
+			}
+			documents, err = m.matchDocuments(ctx, documents, query, NoLimit)
+
+		case LIMIT:
+			limit, ok := stageLimit(arg)
+			if !ok {
+				return nil, errors.New("$limit stage expects a non-negative whole number")
+			}
+			if limit < int64(len(documents)) {
+				documents = documents[:limit]
+			}
+
+		case LOOKUP:
+			lookup, _ := arg.(map[string]interface{})
+			documents, err = m.performLookup(ctx, collections, documents, lookup)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// stageLimit - number of documents kept by $limit stage, a non-negative whole
+// number of any numeric type
+func stageLimit(arg interface{}) (int64, bool) {
+	limit, float, isInt, ok := numberValue(arg)
+	if ok && !isInt {
+		limit = int64(float)
+		isInt = float64(limit) == float
+	}
+
+	return limit, isInt && limit >= 0
+}
+
+func (m *MemJ) parseStage(stage interface{}) (string, interface{}, error) {
+	stageMap, ok := stage.(map[string]interface{})
+	if !ok || len(stageMap) != 1 {
+		return "", nil, errors.New("Aggregation stage must be an object with a single operator")
+	}
+
+	for op, arg := range stageMap {
+		switch op {
+		case MATCH, LIMIT:
+			return op, arg, nil
+
+		case LOOKUP:
+			if _, ok := arg.(map[string]interface{}); !ok {
+				return "", nil, errors.New("$lookup stage expects an object")
+			}
+			return op, arg, nil
+		}
+	}
+
+	return "", nil, errors.New("Unsupported aggregation stage")
+}
+
+// pipelineCollections - collect names of all collections joined by pipeline
+func (m *MemJ) pipelineCollections(pipeline []interface{}, collections map[string]bool) error {
+	for _, stage := range pipeline {
+		op, arg, err := m.parseStage(stage)
+		if err != nil {
+			return err
+		}
+		if op != LOOKUP {
+			continue
+		}
+
+		lookup, _ := arg.(map[string]interface{})
+		from, ok := lookup["from"].(string)
+		if !ok || from == "" {
+			return errors.New("$lookup requires from collection name")
+		}
+		collections[from] = true
+
+		if subPipeline, ok := lookup["pipeline"].([]interface{}); ok {
+			err = m.pipelineCollections(subPipeline, collections)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MemJ) performLookup(ctx context.Context, collections map[string]*collection, documents []map[string]interface{}, lookup map[string]interface{}) ([]map[string]interface{}, error) {
+	from, _ := lookup["from"].(string)
+	as, ok := lookup["as"].(string)
+	if !ok || as == "" {
+		return nil, errors.New("$lookup requires as field name")
+	}
+
+	localField, hasLocal := lookup["localField"].(string)
+	foreignField, hasForeign := lookup["foreignField"].(string)
+	if hasLocal != hasForeign {
+		return nil, errors.New("$lookup requires both localField and foreignField")
+	}
+
+	var subPipeline []interface{}
+	if value, ok := lookup["pipeline"]; ok {
+		subPipeline, ok = value.([]interface{})
+		if !ok {
+			return nil, errors.New("$lookup pipeline must be a list of stages")
+		}
+	} else if !hasLocal {
+		return nil, errors.New("$lookup requires localField and foreignField or pipeline")
+	}
+
+	letVars, _ := lookup["let"].(map[string]interface{})
+
+	allForeignDocs := collections[from].all()
+
+	var results []map[string]interface{}
+	for index, document := range documents {
+		if err := checkContext(ctx, index); err != nil {
+			return nil, err
+		}
+
+		foreignDocs := allForeignDocs
+
+		if hasLocal {
+			localValue := m.getNestedQueryValue(strings.Split(localField, "."), document)
+			foreignKey := strings.Split(foreignField, ".")
+
+			var joined []map[string]interface{}
+			for _, foreignDoc := range foreignDocs {
+				if m.lookupValuesMatch(localValue, m.getNestedQueryValue(foreignKey, foreignDoc)) {
+					joined = append(joined, foreignDoc)
+				}
+			}
+			foreignDocs = joined
+		}
+
+		if subPipeline != nil {
+			vars := make(map[string]interface{}, len(letVars))
+			for name, expr := range letVars {
+				vars[name] = m.resolveLetValue(expr, document)
+			}
+
+			var err error
+			foreignDocs, err = m.runPipeline(ctx, collections, foreignDocs, m.substituteVariables(subPipeline, vars).([]interface{}))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		matches := make([]interface{}, 0, len(foreignDocs))
+		for _, foreignDoc := range foreignDocs {
+			matches = append(matches, foreignDoc)
+		}
+
+		joinedDoc := make(map[string]interface{}, len(document)+1)
+		for k, v := range document {
+			joinedDoc[k] = v
+		}
+		joinedDoc[as] = matches
+		results = append(results, joinedDoc)
+	}
+
+	return results, nil
+}
+
+// resolveLetValue - "$field.path" expressions refer to fields of the local document
+func (m *MemJ) resolveLetValue(expr interface{}, document map[string]interface{}) interface{} {
+	path, ok := expr.(string)
+	if !ok || !strings.HasPrefix(path, "$") || strings.HasPrefix(path, "$$") {
+		return expr
+	}
+
+	return m.getNestedQueryValue(strings.Split(path[1:], "."), document)
+}
+
+// substituteVariables - return copy of value with "$$name" strings replaced by their values
+func (m *MemJ) substituteVariables(value interface{}, vars map[string]interface{}) interface{} {
+	switch value := value.(type) {
+	case string:
+		if strings.HasPrefix(value, "$$") {
+			if v, ok := vars[value[2:]]; ok {
+				return v
+			}
+		}
+		return value
+
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = m.substituteVariables(v, vars)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = m.substituteVariables(v, vars)
+		}
+		return result
+	}
+
+	return value
+}
+
+// lookupValuesMatch - local array values match if any element matches
+func (m *MemJ) lookupValuesMatch(localValue, foreignValue interface{}) bool {
+	if localList, ok := localValue.([]interface{}); ok {
+		for _, v := range localList {
+			if m.valuesEqual(v, foreignValue) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return m.valuesEqual(localValue, foreignValue)
+}
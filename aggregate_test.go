@@ -0,0 +1,268 @@
+package memj
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func insertOrdersAndCustomers(t *testing.T, memj *MemJ) bool {
+	for i := 0; i < 3; i++ {
+		payloadText := fmt.Sprintf(`{"CustomerID": "cust-%d", "Name": "Customer-%d"}`, i, i)
+		var payload map[string]interface{}
+		err := json.Unmarshal([]byte(payloadText), &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return false
+		}
+
+		_, err = memj.Insert("Customers", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		payloadText := fmt.Sprintf(`{"OrderID": %d, "CustomerID": "cust-%d", "Total": %d}`, i, i%2, i*10)
+		var payload map[string]interface{}
+		err := json.Unmarshal([]byte(payloadText), &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return false
+		}
+
+		_, err = memj.Insert("Orders", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestAggregateLookup(t *testing.T) {
+	memj, _ := New()
+	if !insertOrdersAndCustomers(t, memj) {
+		return
+	}
+
+	var jsonPipeline = []byte(`[
+		{"$match": {"CustomerID": "cust-0"}},
+		{"$lookup": {"from": "Orders", "localField": "CustomerID", "foreignField": "CustomerID", "as": "Orders"}}
+	]`)
+	var pipeline []interface{}
+	err := json.Unmarshal(jsonPipeline, &pipeline)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	documents, err := memj.Aggregate("Customers", pipeline)
+
+	if err != nil {
+		t.Error("Error in Aggregate: ", err)
+		return
+	}
+
+	if len(documents) != 1 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	orders, ok := documents[0]["Orders"].([]interface{})
+	if !ok || len(orders) != 5 {
+		t.Error("Incorrect number of joined documents")
+		return
+	}
+
+	customer, _ := memj.Find("Customers", documents[0]["objectid"].(string))
+	if _, ok := customer["Orders"]; ok {
+		t.Error("Lookup modified stored document")
+		return
+	}
+}
+
+func TestAggregateLookupNoMatches(t *testing.T) {
+	memj, _ := New()
+	if !insertOrdersAndCustomers(t, memj) {
+		return
+	}
+
+	var jsonPipeline = []byte(`[
+		{"$match": {"CustomerID": "cust-2"}},
+		{"$lookup": {"from": "Orders", "localField": "CustomerID", "foreignField": "CustomerID", "as": "Orders"}}
+	]`)
+	var pipeline []interface{}
+	err := json.Unmarshal(jsonPipeline, &pipeline)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	documents, err := memj.Aggregate("Customers", pipeline)
+
+	if err != nil {
+		t.Error("Error in Aggregate: ", err)
+		return
+	}
+
+	orders, ok := documents[0]["Orders"].([]interface{})
+	if !ok || len(orders) != 0 {
+		t.Error("Expected empty list of joined documents")
+		return
+	}
+}
+
+func TestAggregateLookupPipelineWithLet(t *testing.T) {
+	memj, _ := New()
+	if !insertOrdersAndCustomers(t, memj) {
+		return
+	}
+
+	var jsonPipeline = []byte(`[
+		{"$match": {"CustomerID": "cust-1"}},
+		{"$lookup": {
+			"from": "Orders",
+			"let": {"customer": "$CustomerID"},
+			"pipeline": [
+				{"$match": {"$and": [{"CustomerID": "$$customer"}, {"Total": {"$gte": 50}}]}},
+				{"$limit": 2}
+			],
+			"as": "BigOrders"
+		}}
+	]`)
+	var pipeline []interface{}
+	err := json.Unmarshal(jsonPipeline, &pipeline)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	documents, err := memj.Aggregate("Customers", pipeline)
+
+	if err != nil {
+		t.Error("Error in Aggregate: ", err)
+		return
+	}
+
+	if len(documents) != 1 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	orders, ok := documents[0]["BigOrders"].([]interface{})
+	if !ok || len(orders) != 2 {
+		t.Error("Incorrect number of joined documents")
+		return
+	}
+
+	for _, order := range orders {
+		orderDoc := order.(map[string]interface{})
+		if orderDoc["CustomerID"] != "cust-1" || orderDoc["Total"].(float64) < 50 {
+			t.Error("Wrong document joined")
+			return
+		}
+	}
+}
+
+func TestAggregateLookupMissingAs(t *testing.T) {
+	memj, _ := New()
+	if !insertOrdersAndCustomers(t, memj) {
+		return
+	}
+
+	var jsonPipeline = []byte(`[{"$lookup": {"from": "Orders", "localField": "CustomerID", "foreignField": "CustomerID"}}]`)
+	var pipeline []interface{}
+	err := json.Unmarshal(jsonPipeline, &pipeline)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	_, err = memj.Aggregate("Customers", pipeline)
+
+	if err == nil {
+		t.Error("Invalid $lookup but no error")
+		return
+	}
+}
+
+func TestAggregateLookupMixedNumbers(t *testing.T) {
+	memj, _ := New()
+	if _, err := memj.InsertJSON("Customers", `{"CustomerID": 1}`); err != nil {
+		t.Error("Error inserting customer: ", err)
+		return
+	}
+	memj.Insert("Orders", map[string]interface{}{"CustomerID": float64(1)})
+
+	results, err := memj.Aggregate("Customers", []interface{}{
+		map[string]interface{}{LOOKUP: map[string]interface{}{
+			"from": "Orders", "localField": "CustomerID", "foreignField": "CustomerID", "as": "Orders",
+		}},
+	})
+	if err != nil || len(results) != 1 || len(results[0]["Orders"].([]interface{})) != 1 {
+		t.Error("Expected int64 and float64 ids to join, got ", results, err)
+		return
+	}
+}
+
+func TestAggregateLimit(t *testing.T) {
+	memj, _ := New()
+	if !insertOrdersAndCustomers(t, memj) {
+		return
+	}
+
+	jsonStage, err := ParseJSON(`{"$limit": 2}`)
+	if err != nil {
+		t.Error("Error parsing stage: ", err)
+		return
+	}
+
+	pipelines := [][]interface{}{
+		{map[string]interface{}{LIMIT: 2}},
+		{map[string]interface{}{LIMIT: float64(2)}},
+		{jsonStage},
+	}
+	for _, pipeline := range pipelines {
+		documents, err := memj.Aggregate("Orders", pipeline)
+		if err != nil || len(documents) != 2 {
+			t.Error("Expected 2 documents limited by ", pipeline, ", got ", len(documents), err)
+			return
+		}
+	}
+
+	for _, limit := range []interface{}{-1, 1.5, "2"} {
+		if _, err := memj.Aggregate("Orders", []interface{}{map[string]interface{}{LIMIT: limit}}); err == nil {
+			t.Error("Expected error for $limit ", limit)
+			return
+		}
+	}
+}
+
+func TestAggregateUnsupportedStage(t *testing.T) {
+	memj, _ := New()
+
+	var jsonPipeline = []byte(`[{"$group": {"_id": "$CustomerID"}}]`)
+	var pipeline []interface{}
+	err := json.Unmarshal(jsonPipeline, &pipeline)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	_, err = memj.Aggregate("Customers", pipeline)
+
+	if err == nil {
+		t.Error("Unsupported stage but no error")
+		return
+	}
+}
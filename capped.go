@@ -0,0 +1,105 @@
+package memj
+
+import (
+	"errors"
+)
+
+// ErrCappedDelete - returned when deleting documents from capped collection,
+// capped collections only lose their oldest documents by eviction
+var ErrCappedDelete = errors.New("Cannot delete documents from capped collection")
+
+// ErrCappedPositionLost - returned by tailable cursor when documents it has
+// not read yet were evicted
+var ErrCappedPositionLost = errors.New("Tailable cursor position was evicted from capped collection")
+
+// cappedLimits - limits of capped collection and approximate size of its
+// documents
+type cappedLimits struct {
+	maxDocuments int
+	maxSize      int64
+	size         int64
+}
+
+func newCappedLimits(options CollectionOptions) (*cappedLimits, error) {
+	if options.MaxDocuments < 0 || options.MaxSize < 0 {
+		return nil, errors.New("Capped collection limits must not be negative")
+	}
+	if options.MaxDocuments == 0 && options.MaxSize == 0 {
+		return nil, nil
+	}
+	if options.TTL != nil {
+		return nil, errors.New("Capped collection cannot have TTL")
+	}
+
+	return &cappedLimits{maxDocuments: options.MaxDocuments, maxSize: options.MaxSize}, nil
+}
+
+// limitsSize - collection is capped by size of its documents
+func (l *cappedLimits) limitsSize() bool {
+	return l != nil && l.maxSize > 0
+}
+
+// full - collection holding count documents is over its limits
+func (l *cappedLimits) full(count int) bool {
+	return (l.maxDocuments > 0 && count > l.maxDocuments) || (l.maxSize > 0 && l.size > l.maxSize)
+}
+
+// appendCapped - add document to store of collection evicting oldest
+// documents while capped collection is over its limits, caller holds write
+// lock of store
+//
+// Capped collections are never sharded.  Eviction advances the start of
+// c.documents, so each insert costs constant time on average whatever the
+// size of collection.
+func (m *MemJ) appendCapped(c *collection, s *store, document map[string]interface{}) error {
+	if c.capped == nil {
+		c.appendDocument(s, document)
+		return nil
+	}
+
+	if c.capped.limitsSize() {
+		size := m.approximateSize(document)
+		if size > c.capped.maxSize {
+			return errors.New("Document is larger than capped collection")
+		}
+		c.capped.size += size
+	}
+
+	c.appendDocument(s, document)
+	m.evictCapped(c)
+
+	return nil
+}
+
+// evictCapped - remove oldest documents while capped collection is over its
+// limits, caller holds collection write lock
+func (m *MemJ) evictCapped(c *collection) {
+	for len(c.documents) > 0 && c.capped.full(len(c.documents)) {
+		document := c.documents[0]
+		c.documents = c.documents[1:]
+		c.sequences = c.sequences[1:]
+		c.first++
+
+		objectID, _ := document[c.primaryKey].(string)
+		delete(c.ids, objectID)
+		if c.capped.limitsSize() {
+			c.capped.size -= m.approximateSize(document)
+		}
+	}
+}
+
+// resetCapped - recount size of documents replaced by setDocuments and evict
+// oldest ones over the limits, caller holds collection write lock
+func (m *MemJ) resetCapped(c *collection) {
+	if c.capped == nil {
+		return
+	}
+
+	c.capped.size = 0
+	if c.capped.limitsSize() {
+		for _, document := range c.documents {
+			c.capped.size += m.approximateSize(document)
+		}
+	}
+	m.evictCapped(c)
+}
@@ -0,0 +1,265 @@
+package memj
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func insertSequence(t *testing.T, memj *MemJ, collection string, from, to int) bool {
+	for i := from; i < to; i++ {
+		_, err := memj.Insert(collection, map[string]interface{}{"objectid": strconv.Itoa(i), "n": float64(i)})
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCappedMaxDocuments(t *testing.T) {
+	memj, _ := New()
+	err := memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 3})
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	if !insertSequence(t, memj, "Log", 0, 5) {
+		return
+	}
+
+	documents, _ := memj.FindAll("Log")
+	if len(documents) != 3 || documents[0]["n"] != float64(2) || documents[2]["n"] != float64(4) {
+		t.Error("Expected newest 3 documents in insertion order, got ", documents)
+		return
+	}
+
+	// ids of evicted documents can be used again
+	if _, err := memj.Find("Log", "0"); err != ErrNotFound {
+		t.Error("Expected evicted document to be gone, got ", err)
+		return
+	}
+	if !insertSequence(t, memj, "Log", 0, 1) {
+		return
+	}
+
+	if _, err := memj.Delete("Log", "4"); err != ErrCappedDelete {
+		t.Error("Expected ErrCappedDelete, got ", err)
+		return
+	}
+
+	isUpdated, err := memj.Update("Log", "4", map[string]interface{}{"n": float64(40)})
+	if !isUpdated || err != nil {
+		t.Error("Expected update of capped collection, got ", err)
+		return
+	}
+
+	removed, err := memj.Truncate("Log")
+	if removed != 3 || err != nil {
+		t.Error("Expected truncate to remove 3 documents, got ", removed, err)
+		return
+	}
+	if !insertSequence(t, memj, "Log", 10, 14) {
+		return
+	}
+	if count, _ := memj.Count("Log", map[string]interface{}{"n": map[string]interface{}{"$gte": float64(0)}}); count != 3 {
+		t.Error("Expected cap kept after truncate, got ", count)
+		return
+	}
+}
+
+func TestCappedMaxSize(t *testing.T) {
+	memj, _ := New()
+	document := map[string]interface{}{"objectid": "0", "text": "abcdefgh"}
+	size := memj.approximateSize(document)
+
+	err := memj.CreateCollection("Log", CollectionOptions{MaxSize: 2*size + size/2})
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	for i := 0; i < 4; i++ {
+		_, err := memj.Insert("Log", map[string]interface{}{"objectid": strconv.Itoa(i), "text": "abcdefgh"})
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	documents, _ := memj.FindAll("Log")
+	if len(documents) != 2 || documents[0]["objectid"] != "2" {
+		t.Error("Expected newest 2 documents, got ", documents)
+		return
+	}
+
+	_, err = memj.Insert("Log", map[string]interface{}{"text": string(make([]byte, 3*size))})
+	if err == nil {
+		t.Error("Expected error inserting document larger than collection")
+		return
+	}
+
+	_, err = memj.Update("Log", "3", map[string]interface{}{"text": "abcdefghijk"})
+	if err == nil {
+		t.Error("Expected error changing size of document")
+		return
+	}
+	_, err = memj.Update("Log", "3", map[string]interface{}{"text": "hgfedcba"})
+	if err != nil {
+		t.Error("Error updating document keeping its size: ", err)
+		return
+	}
+}
+
+func TestCappedOptions(t *testing.T) {
+	memj, _ := New()
+
+	if err := memj.CreateCollection("Log", CollectionOptions{MaxDocuments: -1}); err == nil {
+		t.Error("Expected error for negative limit")
+		return
+	}
+	if err := memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 1, TTL: &TTL{Field: "at"}}); err == nil {
+		t.Error("Expected error for capped collection with TTL")
+		return
+	}
+
+	if err := memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 2}); err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+	if err := memj.SetTTL("Log", &TTL{Field: "at"}); err == nil {
+		t.Error("Expected error setting TTL of capped collection")
+		return
+	}
+
+	if !insertSequence(t, memj, "Log", 0, 2) {
+		return
+	}
+	if err := memj.RenameCollection("Log", "Events"); err != nil {
+		t.Error("Error renaming collection: ", err)
+		return
+	}
+	if !insertSequence(t, memj, "Events", 2, 3) {
+		return
+	}
+	if documents, _ := memj.FindAll("Events"); len(documents) != 2 {
+		t.Error("Expected renamed collection to stay capped, got ", documents)
+		return
+	}
+}
+
+func TestCappedLoad(t *testing.T) {
+	source, _ := New()
+	if !insertSequence(t, source, "Log", 0, 5) {
+		return
+	}
+	var snapshot bytes.Buffer
+	if err := source.Save(&snapshot); err != nil {
+		t.Error("Error saving: ", err)
+		return
+	}
+
+	memj, _ := New()
+	memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 2})
+	if err := memj.Load(&snapshot); err != nil {
+		t.Error("Error loading: ", err)
+		return
+	}
+
+	documents, _ := memj.FindAll("Log")
+	if len(documents) != 2 || documents[0]["objectid"] != "3" {
+		t.Error("Expected newest 2 loaded documents, got ", documents)
+		return
+	}
+}
+
+func TestTailableCursor(t *testing.T) {
+	memj, _ := New()
+	memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 10})
+	if !insertSequence(t, memj, "Log", 0, 3) {
+		return
+	}
+
+	if _, err := memj.QueryCursor("Other", map[string]interface{}{}, CursorOptions{Tailable: true}); err == nil {
+		t.Error("Expected error for tailable cursor over collection that is not capped")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := map[string]interface{}{"n": map[string]interface{}{"$gte": float64(1)}}
+	cursor, err := memj.QueryCursorCtx(ctx, "Log", query, CursorOptions{Tailable: true, BatchSize: 2})
+	if err != nil {
+		t.Error("Error opening cursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		insertSequence(t, memj, "Log", 3, 6)
+	}()
+
+	for expected := 1; expected < 6; expected++ {
+		if !cursor.Next() {
+			t.Error("Expected document, got ", cursor.Err())
+			return
+		}
+		if cursor.Document()["n"] != float64(expected) {
+			t.Error("Expected document ", expected, " got ", cursor.Document())
+			return
+		}
+	}
+
+	cancel()
+	if cursor.Next() || cursor.Err() != context.Canceled {
+		t.Error("Expected cursor to stop with context error, got ", cursor.Err())
+		return
+	}
+}
+
+func TestTailableCursorPositionLost(t *testing.T) {
+	memj, _ := New()
+	memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 2})
+	if !insertSequence(t, memj, "Log", 0, 2) {
+		return
+	}
+
+	cursor, err := memj.QueryCursor("Log", map[string]interface{}{"n": map[string]interface{}{"$gte": float64(0)}}, CursorOptions{Tailable: true, BatchSize: 1})
+	if err != nil {
+		t.Error("Error opening cursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	if !cursor.Next() {
+		t.Error("Expected document, got ", cursor.Err())
+		return
+	}
+
+	if !insertSequence(t, memj, "Log", 2, 5) {
+		return
+	}
+	if cursor.Next() || cursor.Err() != ErrCappedPositionLost {
+		t.Error("Expected ErrCappedPositionLost, got ", cursor.Err())
+		return
+	}
+
+	dropped, err := memj.QueryCursor("Log", map[string]interface{}{"n": map[string]interface{}{"$gte": float64(0)}}, CursorOptions{Tailable: true})
+	if err != nil {
+		t.Error("Error opening cursor: ", err)
+		return
+	}
+	defer dropped.Close()
+
+	memj.DropCollection("Log")
+	if dropped.Next() || dropped.Err() == nil {
+		t.Error("Expected error after collection was dropped")
+		return
+	}
+}
@@ -0,0 +1,28 @@
+package memj
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Error("Incorrect start time: ", clock.Now())
+		return
+	}
+
+	clock.Advance(time.Hour)
+	if !clock.Now().Equal(start.Add(time.Hour)) {
+		t.Error("Clock not advanced: ", clock.Now())
+		return
+	}
+
+	clock.Set(start)
+	if !clock.Now().Equal(start) {
+		t.Error("Clock not set: ", clock.Now())
+		return
+	}
+}
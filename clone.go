@@ -0,0 +1,218 @@
+package memj
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCheckpointNotFound - returned by RestoreCheckpoint for unknown name
+var ErrCheckpointNotFound = errors.New("Checkpoint not found")
+
+// Clone - independent deep copy of m with all its collections
+//
+// The clone has the same options as m except persistence, it shares the id
+// generator, clock, logger and metrics of m.  Documents of clone and m are
+// never shared.
+func (m *MemJ) Clone() (*MemJ, error) {
+	return m.CloneCtx(context.Background())
+}
+
+// CloneCtx - like Clone but takes context
+func (m *MemJ) CloneCtx(ctx context.Context) (*MemJ, error) {
+	return m.copyInstance(ctx, true)
+}
+
+// Fork - copy of m sharing documents with it until they are written
+//
+// Forking copies only references to documents, updates of m and the fork
+// replace documents with updated copies.  Forks are cheap to create from
+// seed data shared by parallel subtests.  Like Clone, the fork has no
+// persistence.
+func (m *MemJ) Fork() (*MemJ, error) {
+	return m.ForkCtx(context.Background())
+}
+
+// ForkCtx - like Fork but takes context
+func (m *MemJ) ForkCtx(ctx context.Context) (*MemJ, error) {
+	return m.copyInstance(ctx, false)
+}
+
+// Checkpoint - remember state of all collections under name, replacing
+// earlier checkpoint with the same name
+//
+// Like Fork, checkpoint shares documents with m until they are written.
+func (m *MemJ) Checkpoint(name string) error {
+	return m.CheckpointCtx(context.Background(), name)
+}
+
+// CheckpointCtx - like Checkpoint but takes context
+func (m *MemJ) CheckpointCtx(ctx context.Context, name string) error {
+	collections, err := m.copyCollections(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]map[string]*collection)
+	}
+	m.checkpoints[name] = collections
+
+	return nil
+}
+
+// RestoreCheckpoint - reset all collections to state remembered by
+// Checkpoint, collections created since are dropped
+//
+// Checkpoint is kept so it can be restored again, for example between test
+// cases.
+func (m *MemJ) RestoreCheckpoint(name string) error {
+	return m.RestoreCheckpointCtx(context.Background(), name)
+}
+
+// RestoreCheckpointCtx - like RestoreCheckpoint but takes context
+func (m *MemJ) RestoreCheckpointCtx(ctx context.Context, name string) error {
+	m.mutexLock.RLock()
+	checkpoint, ok := m.checkpoints[name]
+	names := make(map[string]bool, len(m.collections)+len(checkpoint))
+	for name := range m.collections {
+		names[name] = true
+	}
+	m.mutexLock.RUnlock()
+
+	if !ok {
+		return ErrCheckpointNotFound
+	}
+	for name := range checkpoint {
+		names[name] = true
+	}
+
+	locked, unlock, err := m.lockCollections(ctx, names, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for name, c := range locked {
+		source, ok := checkpoint[name]
+		if !ok {
+			m.removeCollection(name, c)
+			continue
+		}
+
+		c.assign(source)
+		if c.ttl != nil {
+			m.startReaper()
+		}
+	}
+
+	return nil
+}
+
+// DropCheckpoint - forget checkpoint, returns false when it does not exist
+func (m *MemJ) DropCheckpoint(name string) bool {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	_, ok := m.checkpoints[name]
+	delete(m.checkpoints, name)
+
+	return ok
+}
+
+// copyInstance - new MemJ with options and collections of m
+func (m *MemJ) copyInstance(ctx context.Context, deep bool) (*MemJ, error) {
+	collections, err := m.copyCollections(ctx, deep)
+	if err != nil {
+		return nil, err
+	}
+
+	copied := &MemJ{
+		collections:    collections,
+		idGenerator:    m.idGenerator,
+		primaryKey:     m.primaryKey,
+		copyOnRead:     m.copyOnRead,
+		shards:         m.shards,
+		parallelQuery:  m.parallelQuery,
+		clock:          m.clock,
+		logger:         m.logger,
+		metrics:        m.metrics,
+		reaperInterval: m.reaperInterval,
+	}
+	for _, c := range collections {
+		if c.ttl != nil {
+			copied.startReaper()
+			break
+		}
+	}
+
+	return copied, nil
+}
+
+// copyCollections - unregistered copies of all existing collections, deep
+// copies do not share documents
+//
+// Collections are write locked so that writers to their shards are not
+// running.
+func (m *MemJ) copyCollections(ctx context.Context, deep bool) (map[string]*collection, error) {
+	m.mutexLock.RLock()
+	names := make(map[string]bool, len(m.collections))
+	for name := range m.collections {
+		names[name] = true
+	}
+	m.mutexLock.RUnlock()
+
+	locked, unlock, err := m.lockCollections(ctx, names, true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	collections := make(map[string]*collection, len(locked))
+	for name, c := range locked {
+		if !c.exists() {
+			continue
+		}
+
+		copied := m.newCollection()
+		copied.assign(c)
+		if deep {
+			for _, s := range copied.stores() {
+				for i, document := range s.documents {
+					s.documents[i] = m.copyDocument(document)
+				}
+			}
+		}
+		collections[name] = copied
+	}
+
+	return collections, nil
+}
+
+// assign - replace documents and settings of c with those of source, caller
+// holds write lock of c and source is locked or unregistered
+//
+// Documents are shared, they are never changed in place.
+func (c *collection) assign(source *collection) {
+	c.capped = nil
+	if source.capped != nil {
+		c.unshard()
+		capped := *source.capped
+		c.capped = &capped
+		if c.changed == nil {
+			c.changed = make(chan struct{})
+		}
+	}
+
+	documents := source.merged()
+	if source.shards == nil {
+		documents = make([]map[string]interface{}, len(source.documents))
+		copy(documents, source.documents)
+	}
+	c.setDocuments(documents)
+
+	c.validator = source.validator
+	c.ttl = source.ttl
+}
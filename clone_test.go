@@ -0,0 +1,157 @@
+package memj
+
+import (
+	"strconv"
+	"testing"
+)
+
+func seedOrders(t *testing.T, memj *MemJ) bool {
+	for i := 0; i < 3; i++ {
+		_, err := memj.Insert("Orders", map[string]interface{}{
+			"objectid": strconv.Itoa(i),
+			"Customer": map[string]interface{}{"Name": "c" + strconv.Itoa(i)},
+		})
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func customerName(memj *MemJ, objectID string) interface{} {
+	document, err := memj.Find("Orders", objectID)
+	if err != nil {
+		return err
+	}
+	customer, _ := document["Customer"].(map[string]interface{})
+
+	return customer["Name"]
+}
+
+func TestClone(t *testing.T) {
+	memj, _ := New()
+	if !seedOrders(t, memj) {
+		return
+	}
+	memj.SetValidator("Orders", map[string]interface{}{"required": []interface{}{"Customer"}}, ValidatorOptions{})
+
+	clone, err := memj.Clone()
+	if err != nil {
+		t.Error("Error cloning: ", err)
+		return
+	}
+
+	document, _ := clone.Find("Orders", "1")
+	document["Customer"].(map[string]interface{})["Name"] = "changed"
+	if customerName(memj, "1") != "c1" {
+		t.Error("Expected clone documents to be independent")
+		return
+	}
+
+	clone.Delete("Orders", "0")
+	clone.Insert("Items", map[string]interface{}{"Name": "item"})
+	if count, _ := memj.Count("Orders", map[string]interface{}{"$and": []interface{}{}}); count != 3 {
+		t.Error("Expected 3 documents in original, got ", count)
+		return
+	}
+	if names := memj.ListCollections(); len(names) != 1 {
+		t.Error("Expected original collections unchanged, got ", names)
+		return
+	}
+
+	if _, err := clone.Insert("Orders", map[string]interface{}{"Name": "no customer"}); err == nil {
+		t.Error("Expected clone to keep validator")
+		return
+	}
+}
+
+func TestFork(t *testing.T) {
+	memj, _ := New()
+	if !seedOrders(t, memj) {
+		return
+	}
+
+	fork, err := memj.Fork()
+	if err != nil {
+		t.Error("Error forking: ", err)
+		return
+	}
+
+	_, err = fork.Update("Orders", "1", map[string]interface{}{"Customer.Name": "fork"})
+	if err != nil {
+		t.Error("Error updating fork: ", err)
+		return
+	}
+	_, err = memj.Update("Orders", "2", map[string]interface{}{"Customer.Name": "parent"})
+	if err != nil {
+		t.Error("Error updating parent: ", err)
+		return
+	}
+
+	if customerName(fork, "1") != "fork" || customerName(memj, "1") != "c1" {
+		t.Error("Expected update of fork to copy document")
+		return
+	}
+	if customerName(memj, "2") != "parent" || customerName(fork, "2") != "c2" {
+		t.Error("Expected update of parent to copy document")
+		return
+	}
+
+	fork.Delete("Orders", "0")
+	fork.Insert("Orders", map[string]interface{}{"objectid": "3"})
+	if _, err := memj.Find("Orders", "0"); err != nil {
+		t.Error("Expected document deleted in fork to stay in parent")
+		return
+	}
+	if _, err := memj.Find("Orders", "3"); err != ErrNotFound {
+		t.Error("Expected document inserted in fork to be missing in parent")
+		return
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	memj, _ := New()
+	if !seedOrders(t, memj) {
+		return
+	}
+
+	if err := memj.Checkpoint("seed"); err != nil {
+		t.Error("Error creating checkpoint: ", err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		memj.Update("Orders", "1", map[string]interface{}{"Customer.Name": "changed"})
+		memj.Delete("Orders", "2")
+		memj.Insert("Items", map[string]interface{}{"Name": "item"})
+
+		if err := memj.RestoreCheckpoint("seed"); err != nil {
+			t.Error("Error restoring checkpoint: ", err)
+			return
+		}
+
+		if customerName(memj, "1") != "c1" {
+			t.Error("Expected restored document, got ", customerName(memj, "1"))
+			return
+		}
+		if _, err := memj.Find("Orders", "2"); err != nil {
+			t.Error("Expected deleted document to be restored")
+			return
+		}
+		if names := memj.ListCollections(); len(names) != 1 || names[0] != "Orders" {
+			t.Error("Expected collections created after checkpoint to be dropped, got ", names)
+			return
+		}
+	}
+
+	if err := memj.RestoreCheckpoint("missing"); err != ErrCheckpointNotFound {
+		t.Error("Expected ErrCheckpointNotFound, got ", err)
+		return
+	}
+	if !memj.DropCheckpoint("seed") || memj.DropCheckpoint("seed") {
+		t.Error("Expected checkpoint to be dropped once")
+		return
+	}
+}
@@ -0,0 +1,399 @@
+// Command memj inspects and queries MemJ snapshot files written by SaveFile.
+//
+// Usage:
+//
+//	memj -f snapshot.json collections
+//	memj -f snapshot.json import [-format f] <collection> [file]
+//	memj -f snapshot.json query [-limit n] [-pretty] <collection> [query]
+//	memj -f snapshot.json export [-format f] <collection> [query]
+//	memj -f snapshot.json count <collection> [query]
+//	memj [-f snapshot.json] shell
+//
+// The snapshot file can also be given with the MEMJ_FILE environment
+// variable.  Import reads newline delimited JSON, a JSON array or CSV from file
+// or standard input and creates snapshot file when it does not exist, other
+// commands except shell require it.  Formats are those of MemJ.Import and
+// MemJ.Export.  Queries are JSON text as accepted by QueryJSON, an omitted
+// query selects all documents.  Documents are printed one per line as
+// extended JSON.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/robjsliwa/memj"
+	"github.com/robjsliwa/memj/shell"
+)
+
+const usage = `Usage: memj [-f snapshot] [-key field] <command> [arguments]
+
+Commands:
+  collections                                      list collections
+  import [-format f] <collection> [file]           insert NDJSON, JSON array or CSV documents
+  query [-limit n] [-pretty] <collection> [query]  print documents matching query
+  export [-format f] <collection> [query]          print documents as NDJSON, JSON or CSV
+  count <collection> [query]                       count documents matching query
+  shell                                            start interactive shell
+`
+
+// errUsage - command line arguments are invalid, usage was printed
+var errUsage = errors.New("Invalid arguments")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run - execute command line args, returns process exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("memj", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	path := flags.String("f", os.Getenv("MEMJ_FILE"), "snapshot file")
+	primaryKey := flags.String("key", memj.DefaultPrimaryKey, "primary key field of documents")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || (*path == "" && flags.Arg(0) != "shell") {
+		flags.Usage()
+		return 2
+	}
+
+	cmd := &command{path: *path, primaryKey: *primaryKey, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	name, args := flags.Arg(0), flags.Args()[1:]
+	switch name {
+	case "shell":
+		err = cmd.shell(args)
+
+	case "collections":
+		err = cmd.collections(args)
+
+	case "import":
+		err = cmd.importDocuments(args)
+
+	case "query":
+		err = cmd.query(args)
+
+	case "export":
+		err = cmd.export(args)
+
+	case "count":
+		err = cmd.count(args)
+
+	default:
+		fmt.Fprintf(stderr, "memj: unknown command %q\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	if errors.Is(err, errUsage) {
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "memj:", err)
+		return 1
+	}
+
+	return 0
+}
+
+type command struct {
+	path       string
+	primaryKey string
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+}
+
+// open - load snapshot file, missing file is an error unless create is set
+func (c *command) open(create bool) (*memj.MemJ, error) {
+	db, err := memj.New()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.SetPrimaryKey(c.primaryKey); err != nil {
+		return nil, err
+	}
+
+	if c.path == "" && create {
+		return db, nil
+	}
+
+	err = db.LoadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) && create {
+		return db, nil
+	}
+
+	return db, err
+}
+
+// parseArgs - parse subcommand flags and check number of positional arguments
+func (c *command) parseArgs(flags *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	flags.SetOutput(c.stderr)
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, errUsage
+	}
+
+	if flags.NArg() < min || flags.NArg() > max {
+		return nil, errUsage
+	}
+
+	return flags.Args(), nil
+}
+
+func (c *command) collections(args []string) error {
+	_, err := c.parseArgs(flag.NewFlagSet("collections", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range db.ListCollections() {
+		fmt.Fprintln(c.stdout, name)
+	}
+
+	return nil
+}
+
+func (c *command) importDocuments(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "ndjson, json or csv, detected from file name or content when empty")
+
+	args, err := c.parseArgs(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	input := bufio.NewReader(c.stdin)
+	name := ""
+	if len(args) == 2 && args[1] != "-" {
+		file, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = bufio.NewReader(file)
+		name = args[1]
+	}
+
+	if *format == "" {
+		*format, err = detectFormat(name, input)
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := c.open(true)
+	if err != nil {
+		return err
+	}
+
+	count, err := db.Import(args[0], input, memj.Format(*format))
+	if err != nil {
+		return err
+	}
+
+	err = db.SaveFile(c.path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stderr, "imported %d documents into %s\n", count, args[0])
+	return nil
+}
+
+// detectFormat - format from file extension, otherwise json when input starts
+// with array and ndjson when it does not
+func detectFormat(name string, input *bufio.Reader) (string, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return string(memj.FormatCSV), nil
+
+	case ".ndjson", ".jsonl":
+		return string(memj.FormatNDJSON), nil
+	}
+
+	for {
+		b, err := input.ReadByte()
+		if err == io.EOF {
+			return string(memj.FormatNDJSON), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			if b == '[' {
+				return string(memj.FormatJSON), input.UnreadByte()
+			}
+			return string(memj.FormatNDJSON), input.UnreadByte()
+		}
+	}
+}
+
+func (c *command) query(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	limit := flags.Int("limit", memj.NoLimit, "maximum number of documents, 0 for all")
+	pretty := flags.Bool("pretty", false, "indent documents")
+
+	args, err := c.parseArgs(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	if *limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+
+	db, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(args)
+	if err != nil {
+		return err
+	}
+
+	var documents []map[string]interface{}
+	if query != nil {
+		documents, err = db.Query(args[0], query, *limit)
+	} else {
+		documents, err = db.FindAll(args[0])
+		if *limit > 0 && len(documents) > *limit {
+			documents = documents[:*limit]
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.printDocuments(documents, *pretty)
+}
+
+func (c *command) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(memj.FormatNDJSON), "ndjson, json or csv")
+
+	args, err := c.parseArgs(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(args)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Export(args[0], c.stdout, memj.Format(*format), query)
+	return err
+}
+
+func (c *command) count(args []string) error {
+	args, err := c.parseArgs(flag.NewFlagSet("count", flag.ContinueOnError), args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.open(false)
+	if err != nil {
+		return err
+	}
+
+	query, err := parseQuery(args)
+	if err != nil {
+		return err
+	}
+
+	count, err := db.Count(args[0], query)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout, count)
+	return nil
+}
+
+// parseQuery - query given after collection name, nil when it is missing and
+// MatchAll for {} which selects all documents like an empty filter of MongoDB
+func parseQuery(args []string) (map[string]interface{}, error) {
+	if len(args) < 2 || strings.TrimSpace(args[1]) == "" {
+		return nil, nil
+	}
+
+	query, err := memj.ParseJSON(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(query) == 0 {
+		return memj.MatchAll(), nil
+	}
+	return query, nil
+}
+
+// printDocuments - write documents as extended JSON, one per line unless pretty
+func (c *command) printDocuments(documents []map[string]interface{}, pretty bool) error {
+	out := bufio.NewWriter(c.stdout)
+
+	for _, document := range documents {
+		encoded, err := memj.MarshalExtendedJSON(document)
+		if err != nil {
+			return err
+		}
+
+		if pretty {
+			var indented bytes.Buffer
+			err = json.Indent(&indented, encoded, "", "  ")
+			if err != nil {
+				return err
+			}
+			encoded = indented.Bytes()
+		}
+
+		out.Write(encoded)
+		out.WriteByte('\n')
+	}
+
+	return out.Flush()
+}
+
+func (c *command) shell(args []string) error {
+	_, err := c.parseArgs(flag.NewFlagSet("shell", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := c.open(true)
+	if err != nil {
+		return err
+	}
+
+	sh := shell.New(db)
+	sh.SnapshotPath = c.path
+	if home, err := os.UserHomeDir(); err == nil {
+		sh.HistoryFile = filepath.Join(home, ".memj_history")
+	}
+
+	return sh.Run(c.stdin, c.stdout)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestImportQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	code, _, stderr := runCommand(t, `[{"OrderID": "id-1", "Price": 10}, {"OrderID": "id-2", "Price": 20.5}]`,
+		"-f", path, "import", "Orders")
+	if code != 0 {
+		t.Error("Error importing JSON array: ", stderr)
+		return
+	}
+
+	code, _, stderr = runCommand(t, "{\"OrderID\": \"id-3\", \"Price\": 30}\n{\"OrderID\": \"id-4\", \"Price\": 40}\n",
+		"-f", path, "import", "Orders")
+	if code != 0 {
+		t.Error("Error importing NDJSON: ", stderr)
+		return
+	}
+
+	code, stdout, stderr := runCommand(t, "", "-f", path, "query", "Orders", `{"Price": {"$gt": 20}}`)
+	if code != 0 {
+		t.Error("Error querying: ", stderr)
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 {
+		t.Error("Incorrect number of documents returned: ", stdout)
+		return
+	}
+
+	if !strings.Contains(lines[0], `"Price":20.5`) || !strings.Contains(lines[0], `"objectid"`) {
+		t.Error("Incorrect document returned: ", lines[0])
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "query", "-limit", "1", "Orders")
+	if code != 0 || strings.Count(stdout, "\n") != 1 {
+		t.Error("Limit not applied: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "query", "-limit", "1", "Orders", `{}`)
+	if code != 0 || strings.Count(stdout, "\n") != 1 {
+		t.Error("Empty query did not match documents: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "query", "-pretty", "Orders", `{"OrderID": "id-1"}`)
+	if code != 0 || !strings.Contains(stdout, "\n  \"OrderID\": \"id-1\"") {
+		t.Error("Document not indented: ", stdout)
+		return
+	}
+}
+
+func TestCountCollectionsExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	input := filepath.Join(t.TempDir(), "customers.json")
+	os.WriteFile(input, []byte(`{"Name": "Rob", "Created": {"$date": "2024-05-01T12:30:00Z"}}`), 0644)
+
+	runCommand(t, `[{"Price": 1}, {"Price": 2}, {"Price": 3}]`, "-f", path, "import", "Orders")
+	code, _, stderr := runCommand(t, "", "-f", path, "import", "Customers", input)
+	if code != 0 {
+		t.Error("Error importing file: ", stderr)
+		return
+	}
+
+	code, stdout, _ := runCommand(t, "", "-f", path, "collections")
+	if code != 0 || stdout != "Customers\nOrders\n" {
+		t.Error("Incorrect collections listed: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "count", "Orders")
+	if code != 0 || stdout != "3\n" {
+		t.Error("Incorrect count of all documents: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "count", "Orders", `{}`)
+	if code != 0 || stdout != "3\n" {
+		t.Error("Incorrect count of empty query: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "count", "Orders", `{"Price": {"$gte": 2}}`)
+	if code != 0 || stdout != "2\n" {
+		t.Error("Incorrect count of matching documents: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "export", "Orders", `{}`)
+	if code != 0 || strings.Count(stdout, "\n") != 3 {
+		t.Error("Empty query did not export all documents: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "export", "Customers")
+	if code != 0 || !strings.Contains(stdout, `"Created":{"$date":"2024-05-01T12:30:00Z"}`) {
+		t.Error("Date not exported as extended JSON: ", stdout)
+		return
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	code, _, _ := runCommand(t, "", "-f", path, "count", "Orders")
+	if code != 1 {
+		t.Error("Expected error for missing snapshot file")
+		return
+	}
+
+	code, _, _ = runCommand(t, "", "-f", path, "unknown")
+	if code != 2 {
+		t.Error("Expected usage error for unknown command")
+		return
+	}
+
+	code, _, _ = runCommand(t, "", "-f", path, "query")
+	if code != 2 {
+		t.Error("Expected usage error for missing collection")
+		return
+	}
+
+	code, _, _ = runCommand(t, `[{"Price": 1}, 2]`, "-f", path, "import", "Orders")
+	if code != 1 {
+		t.Error("Expected error importing invalid document")
+		return
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Snapshot written after failed import")
+		return
+	}
+}
+
+func TestImportExportCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	input := filepath.Join(t.TempDir(), "users.csv")
+	os.WriteFile(input, []byte("name,age,address.city\nAnn,25,Oslo\nBob,35,Bergen\n"), 0644)
+
+	code, _, stderr := runCommand(t, "", "-f", path, "import", "Users", input)
+	if code != 0 {
+		t.Error("Error importing CSV: ", stderr)
+		return
+	}
+
+	code, stdout, _ := runCommand(t, "", "-f", path, "count", "Users", `{"address.city": "Oslo", "age": 25}`)
+	if code != 0 || stdout != "1\n" {
+		t.Error("CSV not imported with nested fields and types: ", stdout)
+		return
+	}
+
+	code, stdout, _ = runCommand(t, "", "-f", path, "export", "-format", "csv", "Users", `{"age": {"$gt": 30}}`)
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if code != 0 || len(lines) != 2 || !strings.HasSuffix(lines[0], "address.city,age,name") ||
+		!strings.HasSuffix(lines[1], "Bergen,35,Bob") {
+		t.Error("Incorrect CSV export: ", stdout)
+		return
+	}
+
+	code, _, _ = runCommand(t, "", "-f", path, "export", "-format", "xml", "Users")
+	if code != 1 {
+		t.Error("Expected error for unsupported format")
+		return
+	}
+}
+
+func TestPrimaryKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	code, _, stderr := runCommand(t, `{"_id": "order-1", "Price": 10}`, "-f", path, "-key", "_id", "import", "Orders")
+	if code != 0 {
+		t.Error("Error importing with primary key: ", stderr)
+		return
+	}
+
+	code, stdout, stderr := runCommand(t, "", "-f", path, "-key", "_id", "query", "Orders")
+	if code != 0 || !strings.Contains(stdout, `"_id":"order-1"`) || strings.Contains(stdout, "objectid") {
+		t.Error("Incorrect documents with primary key: ", stdout, stderr)
+		return
+	}
+
+	code, _, _ = runCommand(t, "", "-f", path, "query", "Orders")
+	if code != 1 {
+		t.Error("Expected error opening snapshot with different primary key")
+		return
+	}
+}
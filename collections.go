@@ -0,0 +1,612 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lock polling intervals used when waiting for collection lock with context
+const (
+	minLockPoll = 10 * time.Microsecond
+	maxLockPoll = time.Millisecond
+)
+
+// ErrCollectionExists - returned by RenameCollection when target collection
+// exists and by CreateCollection
+var ErrCollectionExists = errors.New("Collection already exists")
+
+// CollectionStats - statistics of collection returned by CollectionStats
+type CollectionStats struct {
+	Name string
+	// Count - number of documents
+	Count int
+	// Size - approximate size of documents in bytes, estimated like BSON
+	// encoding of documents
+	Size int64
+	// AvgObjSize - Size divided by Count
+	AvgObjSize int64
+	// HasValidator - collection has validator set by SetValidator
+	HasValidator bool
+	// IndexSizes - size of indexes by name, MemJ keeps no indexes so queries
+	// scan collections and the map is always empty
+	IndexSizes map[string]int64
+}
+
+// CollectionOptions - settings of collection created by CreateCollection
+type CollectionOptions struct {
+	// Validator - JSON Schema of documents, see SetValidator
+	Validator        map[string]interface{}
+	ValidatorOptions ValidatorOptions
+	// TTL - expiry of documents, see SetTTL
+	TTL *TTL
+	// MaxDocuments - capped collection keeping at most this many newest
+	// documents
+	MaxDocuments int
+	// MaxSize - capped collection keeping newest documents of total
+	// approximate size (see CollectionStats) up to this many bytes
+	MaxSize int64
+}
+
+// CreateCollection - create empty collection with options, ErrCollectionExists
+// is returned when collection already exists
+//
+// Collections are also created by the first write, CreateCollection is only
+// needed for collections with settings.
+func (m *MemJ) CreateCollection(collection string, options CollectionOptions) error {
+	return m.CreateCollectionCtx(context.Background(), collection, options)
+}
+
+// CreateCollectionCtx - like CreateCollection but takes context
+func (m *MemJ) CreateCollectionCtx(ctx context.Context, collection string, options CollectionOptions) error {
+	v, err := newValidator(options.Validator, options.ValidatorOptions)
+	if err != nil {
+		return err
+	}
+	ttl, err := newTTLIndex(options.TTL)
+	if err != nil {
+		return err
+	}
+	capped, err := newCappedLimits(options)
+	if err != nil {
+		return err
+	}
+
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if c.exists() {
+		return ErrCollectionExists
+	}
+
+	if capped != nil {
+		c.unshard()
+	}
+	c.setDocuments([]map[string]interface{}{})
+	c.validator = v
+	c.ttl = ttl
+	if ttl != nil {
+		m.startReaper()
+	}
+	if capped != nil {
+		c.capped = capped
+		c.changed = make(chan struct{})
+	}
+
+	return nil
+}
+
+// ListCollections - return sorted names of collections that have been written to
+func (m *MemJ) ListCollections() []string {
+	m.mutexLock.RLock()
+	defer m.mutexLock.RUnlock()
+
+	names := make([]string, 0, len(m.collections))
+	for name, c := range m.collections {
+		if c.exists() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// DropCollection - remove collection with all its documents and validator
+func (m *MemJ) DropCollection(collection string) (bool, error) {
+	return m.DropCollectionCtx(context.Background(), collection)
+}
+
+// DropCollectionCtx - like DropCollection but takes context
+func (m *MemJ) DropCollectionCtx(ctx context.Context, collection string) (bool, error) {
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	exists := c.exists()
+	m.removeCollection(collection, c)
+
+	return exists, nil
+}
+
+// RenameCollection - rename collection keeping its documents and validator,
+// returns ErrNotFound when collection does not exist and ErrCollectionExists
+// when collection named to exists
+func (m *MemJ) RenameCollection(from, to string) error {
+	return m.RenameCollectionCtx(context.Background(), from, to)
+}
+
+// RenameCollectionCtx - like RenameCollection but takes context
+func (m *MemJ) RenameCollectionCtx(ctx context.Context, from, to string) error {
+	if from == to {
+		return errors.New("Cannot rename collection to itself")
+	}
+
+	locked, unlock, err := m.lockCollections(ctx, map[string]bool{from: true, to: true}, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	source, target := locked[from], locked[to]
+	if !source.exists() {
+		return ErrNotFound
+	}
+	if target.exists() {
+		return ErrCollectionExists
+	}
+
+	if source.capped != nil {
+		target.unshard()
+		target.capped = source.capped
+		target.changed = make(chan struct{})
+	}
+	target.setDocuments(source.merged())
+	target.validator = source.validator
+	target.ttl = source.ttl
+	m.removeCollection(from, source)
+
+	return nil
+}
+
+// Truncate - remove all documents from collection keeping its validator,
+// returns number of removed documents
+func (m *MemJ) Truncate(collection string) (int, error) {
+	return m.TruncateCtx(context.Background(), collection)
+}
+
+// TruncateCtx - like Truncate but takes context
+func (m *MemJ) TruncateCtx(ctx context.Context, collection string) (int, error) {
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	removed := c.count()
+	if c.exists() {
+		c.setDocuments([]map[string]interface{}{})
+		m.resetCapped(c)
+	}
+
+	return removed, nil
+}
+
+// DropAll - remove all collections with their documents and validators,
+// useful to reset store between test cases
+func (m *MemJ) DropAll() error {
+	return m.DropAllCtx(context.Background())
+}
+
+// DropAllCtx - like DropAll but takes context
+func (m *MemJ) DropAllCtx(ctx context.Context) error {
+	m.mutexLock.RLock()
+	names := make(map[string]bool, len(m.collections))
+	for name := range m.collections {
+		names[name] = true
+	}
+	m.mutexLock.RUnlock()
+
+	locked, unlock, err := m.lockCollections(ctx, names, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for name, c := range locked {
+		m.removeCollection(name, c)
+	}
+
+	return nil
+}
+
+// CollectionStats - return statistics of collection, ErrNotFound when it does
+// not exist
+func (m *MemJ) CollectionStats(collection string) (CollectionStats, error) {
+	return m.CollectionStatsCtx(context.Background(), collection)
+}
+
+// CollectionStatsCtx - like CollectionStats but takes context
+func (m *MemJ) CollectionStatsCtx(ctx context.Context, collection string) (CollectionStats, error) {
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return CollectionStats{}, err
+	}
+
+	if !c.exists() {
+		return CollectionStats{}, ErrNotFound
+	}
+
+	documents := c.all()
+	stats := CollectionStats{
+		Name:         collection,
+		Count:        len(documents),
+		HasValidator: c.validator != nil,
+		IndexSizes:   map[string]int64{},
+	}
+	for index, document := range documents {
+		if err := checkContext(ctx, index); err != nil {
+			return CollectionStats{}, err
+		}
+		stats.Size += m.approximateSize(document)
+	}
+	if stats.Count > 0 {
+		stats.AvgObjSize = stats.Size / int64(stats.Count)
+	}
+
+	return stats, nil
+}
+
+// approximateSize - size of value as if encoded in BSON, key names are counted
+// by the enclosing document
+func (m *MemJ) approximateSize(value interface{}) int64 {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		size := int64(5)
+		for k, v := range value {
+			size += 2 + int64(len(k)) + m.approximateSize(v)
+		}
+		return size
+
+	case []interface{}:
+		size := int64(5)
+		for i, v := range value {
+			size += 2 + int64(len(strconv.Itoa(i))) + m.approximateSize(v)
+		}
+		return size
+
+	case string:
+		return 5 + int64(len(value))
+
+	case bool:
+		return 1
+
+	case nil:
+		return 0
+
+	case time.Time:
+		return 8
+	}
+
+	return 8
+}
+
+// collection - documents and validator of collection guarded by its lock
+//
+// Every collection name maps to exactly one collection in MemJ.collections.
+// Dropping a collection removes it from the map and marks it dropped, anyone
+// who looked it up before the drop takes the lock again on the collection
+// that replaced it.
+type collection struct {
+	// store - documents of unsharded collection, its lock is the collection
+	// lock
+	store
+	// shards - stores of sharded collection partitioned by hash of primary
+	// key, documents of the collection's own store are not used
+	shards []*store
+	// sequence - last insertion sequence number given to document
+	sequence atomic.Uint64
+	// merge - merges shards of version into its documents once, see all
+	merge sync.Once
+
+	// primaryKey - field holding id of documents
+	primaryKey string
+	validator  *validator
+	ttl        *ttlIndex
+	dropped    bool
+	// capped - limits of capped collection, nil for other collections
+	capped *cappedLimits
+	// first - sequence number of documents[0], appended documents are
+	// numbered consecutively so tailable cursors find their position after
+	// eviction
+	first uint64
+	// changed - closed and replaced when documents of capped collection are
+	// appended, replaced or dropped, wakes tailable cursors
+	changed chan struct{}
+
+	// created - documents have been written to collection, read by
+	// ListCollections without taking the collection lock
+	created atomic.Bool
+}
+
+// exists - collection has been written to, truncated collection still exists
+func (c *collection) exists() bool {
+	return c.created.Load()
+}
+
+// setDocuments - replace documents of collection, caller holds its write lock
+//
+// Documents of unsharded collection keep the given slice, which may be shared
+// with versions of collection it came from.
+func (c *collection) setDocuments(documents []map[string]interface{}) {
+	// replacing documents continue numbering after the replaced ones
+	c.first += uint64(c.count())
+	for _, s := range c.stores() {
+		s.documents, s.sequences = nil, nil
+		s.ids = make(map[string]bool)
+	}
+	if c.shards == nil {
+		c.documents = documents
+		c.sequences = make([]uint64, 0, len(documents))
+		c.published.Store(true)
+	}
+
+	for _, document := range documents {
+		objectID, _ := document[c.primaryKey].(string)
+		s := c.shard(objectID)
+		if c.shards != nil {
+			s.documents = append(s.documents, document)
+		}
+		s.sequences = append(s.sequences, c.sequence.Add(1))
+		s.ids[objectID] = true
+	}
+	c.created.Store(true)
+	c.notify()
+}
+
+// appendDocument - add document with primary key to store of collection,
+// caller holds write lock of store
+func (c *collection) appendDocument(s *store, document map[string]interface{}) {
+	if s.ids == nil {
+		s.ids = make(map[string]bool)
+	}
+	objectID, _ := document[c.primaryKey].(string)
+	s.ids[objectID] = true
+	s.documents = append(s.documents, document)
+	s.sequences = append(s.sequences, c.sequence.Add(1))
+	c.created.Store(true)
+	c.notify()
+}
+
+// notify - wake tailable cursors waiting for changes of capped collection,
+// caller holds its write lock
+func (c *collection) notify() {
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = make(chan struct{})
+	}
+}
+
+// getCollection - collection registered under name, when it does not exist it
+// is registered if create is set and nil returned otherwise
+func (m *MemJ) getCollection(name string, create bool) *collection {
+	m.mutexLock.RLock()
+	c := m.collections[name]
+	m.mutexLock.RUnlock()
+
+	if c != nil || !create {
+		return c
+	}
+
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	// registered by another goroutine since read lock was released
+	c = m.collections[name]
+	if c == nil {
+		c = m.newCollection()
+		m.collections[name] = c
+	}
+
+	return c
+}
+
+// removeCollection - unregister collection, caller holds its write lock
+func (m *MemJ) removeCollection(name string, c *collection) {
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	if m.collections[name] == c {
+		delete(m.collections, name)
+	}
+	for _, s := range c.stores() {
+		s.documents, s.sequences, s.ids = nil, nil, nil
+	}
+	c.validator = nil
+	c.ttl = nil
+	c.capped = nil
+	c.dropped = true
+	c.created.Store(false)
+	c.notify()
+}
+
+// lockCollection - write lock collection registering it when needed, returns
+// collection and unlock function
+func (m *MemJ) lockCollection(ctx context.Context, name string) (*collection, func(), error) {
+	locked, unlock, err := m.lockCollections(ctx, map[string]bool{name: true}, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return locked[name], unlock, nil
+}
+
+// lockWrite - lock collection for writing single documents, returns
+// collection and unlock function
+//
+// Unsharded collection is write locked.  Sharded collection is read locked,
+// so operations on whole collection wait, and writers lock the shard of their
+// document with lockShard.  Collections are only ever changed from sharded to
+// unsharded, never back.
+func (m *MemJ) lockWrite(ctx context.Context, name string) (*collection, func(), error) {
+	for {
+		c := m.getCollection(name, true)
+		err := c.lockContext(ctx, false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if c.dropped {
+			c.lock.RUnlock()
+			continue
+		}
+		if c.shards != nil {
+			return c, c.lock.RUnlock, nil
+		}
+		c.lock.RUnlock()
+
+		return m.lockCollection(ctx, name)
+	}
+}
+
+// rLockCollection - read lock collection, collection that does not exist is
+// returned empty without registering it
+func (m *MemJ) rLockCollection(ctx context.Context, name string) (*collection, func(), error) {
+	locked, unlock, err := m.lockCollections(ctx, map[string]bool{name: true}, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return locked[name], unlock, nil
+}
+
+// rLockCollections - read lock collections in sorted order so that concurrent
+// multi-collection readers and writers cannot deadlock
+func (m *MemJ) rLockCollections(ctx context.Context, names map[string]bool) (map[string]*collection, func(), error) {
+	return m.lockCollections(ctx, names, false)
+}
+
+// lockCollections - lock collections in sorted order, write locks when
+// exclusive is set
+//
+// Writers register missing collections, readers get empty unregistered
+// collection for them.  Collections dropped while waiting for their lock are
+// looked up and locked again.  Waiting for locks ends with context error when
+// ctx is done, locks already taken are released.  Expired documents are
+// removed from write locked collections.
+func (m *MemJ) lockCollections(ctx context.Context, names map[string]bool, exclusive bool) (map[string]*collection, func(), error) {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for {
+		locked := make(map[string]*collection, len(sorted))
+		held := make([]*collection, 0, len(sorted))
+		unlock := func() {
+			for i := len(held) - 1; i >= 0; i-- {
+				if exclusive {
+					held[i].lock.Unlock()
+				} else {
+					held[i].lock.RUnlock()
+				}
+			}
+		}
+
+		for _, name := range sorted {
+			c := m.getCollection(name, exclusive)
+			if c == nil {
+				locked[name] = &collection{primaryKey: m.primaryKey}
+				continue
+			}
+
+			err := c.lockContext(ctx, exclusive)
+			if err != nil {
+				unlock()
+				return nil, nil, err
+			}
+			locked[name] = c
+			held = append(held, c)
+		}
+
+		dropped := false
+		for _, c := range held {
+			dropped = dropped || c.dropped
+		}
+		if !dropped {
+			// writers never see expired documents
+			if exclusive {
+				for _, c := range held {
+					m.removeExpired(c)
+				}
+			}
+			return locked, unlock, nil
+		}
+		unlock()
+	}
+}
+
+// lockContext - take collection lock, waiting until ctx is done
+//
+// sync.RWMutex cannot be waited for with a context, so when ctx can be done
+// the lock is polled with TryLock backing off up to maxLockPoll.  A polling
+// writer does not hold back new readers the way a blocked Lock does, so under
+// steady read load it may wait until ctx is done.
+func (s *store) lockContext(ctx context.Context, exclusive bool) error {
+	if ctx.Done() == nil {
+		if exclusive {
+			s.lock.Lock()
+		} else {
+			s.lock.RLock()
+		}
+		return nil
+	}
+
+	tryLock := s.lock.TryRLock
+	if exclusive {
+		tryLock = s.lock.TryLock
+	}
+
+	wait := minLockPoll
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if tryLock() {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+
+		case <-timer.C:
+		}
+
+		wait *= 2
+		if wait > maxLockPoll {
+			wait = maxLockPoll
+		}
+	}
+}
+
+// contextCheckInterval - number of documents scanned between context checks
+const contextCheckInterval = 64
+
+// checkContext - context error every contextCheckInterval scanned documents
+func checkContext(ctx context.Context, scanned int) error {
+	if scanned%contextCheckInterval != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,324 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListDropCollection(t *testing.T) {
+	memj, _ := New()
+
+	memj.Insert("Orders", map[string]interface{}{"OrderID": "id-1"})
+	memj.Insert("Customers", map[string]interface{}{"Name": "Rob"})
+
+	collections := memj.ListCollections()
+	if len(collections) != 2 || collections[0] != "Customers" || collections[1] != "Orders" {
+		t.Error("Incorrect collections listed: ", collections)
+		return
+	}
+
+	dropped, err := memj.DropCollection("Orders")
+	if err != nil || !dropped {
+		t.Error("Collection not dropped: ", err)
+		return
+	}
+
+	dropped, _ = memj.DropCollection("Orders")
+	if dropped {
+		t.Error("Missing collection reported as dropped")
+		return
+	}
+
+	if _, ok := memj.collections["Orders"]; ok {
+		t.Error("Lock of dropped collection not removed")
+		return
+	}
+
+	collections = memj.ListCollections()
+	if len(collections) != 1 || collections[0] != "Customers" {
+		t.Error("Incorrect collections after drop: ", collections)
+		return
+	}
+}
+
+func TestRenameCollection(t *testing.T) {
+	memj, _ := New()
+
+	objectID, _ := memj.Insert("Orders", map[string]interface{}{"OrderID": "id-1"})
+	memj.SetValidator("Orders", map[string]interface{}{"required": []interface{}{"OrderID"}}, ValidatorOptions{})
+	memj.Insert("Archive", map[string]interface{}{"OrderID": "id-0"})
+
+	err := memj.RenameCollection("Orders", "Archive")
+	if err != ErrCollectionExists {
+		t.Error("Expected ErrCollectionExists, got ", err)
+		return
+	}
+
+	err = memj.RenameCollection("Missing", "Other")
+	if err != ErrNotFound {
+		t.Error("Expected ErrNotFound, got ", err)
+		return
+	}
+
+	err = memj.RenameCollection("Orders", "Orders2024")
+	if err != nil {
+		t.Error("Error renaming collection: ", err)
+		return
+	}
+
+	if _, err := memj.Find("Orders2024", objectID); err != nil {
+		t.Error("Document not moved to renamed collection")
+		return
+	}
+
+	if count, _ := memj.Count("Orders", nil); count != 0 {
+		t.Error("Documents left in old collection")
+		return
+	}
+
+	_, err = memj.Insert("Orders2024", map[string]interface{}{"Price": 1})
+	if err == nil {
+		t.Error("Validator not moved to renamed collection")
+		return
+	}
+
+	collections := memj.ListCollections()
+	if len(collections) != 2 || collections[0] != "Archive" || collections[1] != "Orders2024" {
+		t.Error("Incorrect collections after rename: ", collections)
+		return
+	}
+}
+
+func TestTruncateDropAll(t *testing.T) {
+	memj, _ := New()
+
+	insertOrders(t, memj, 10)
+	memj.SetValidator("TestCollection", map[string]interface{}{"required": []interface{}{"OrderID"}}, ValidatorOptions{})
+	memj.Insert("Customers", map[string]interface{}{"Name": "Rob"})
+
+	removed, err := memj.Truncate("TestCollection")
+	if err != nil || removed != 10 {
+		t.Error("Incorrect number of truncated documents: ", removed, err)
+		return
+	}
+
+	if count, _ := memj.Count("TestCollection", nil); count != 0 {
+		t.Error("Documents left after truncate")
+		return
+	}
+
+	if _, err := memj.Insert("TestCollection", map[string]interface{}{"Price": 1}); err == nil {
+		t.Error("Validator removed by truncate")
+		return
+	}
+
+	err = memj.DropAll()
+	if err != nil {
+		t.Error("Error dropping all collections: ", err)
+		return
+	}
+
+	if len(memj.ListCollections()) != 0 || len(memj.collections) != 0 {
+		t.Error("Collections left after DropAll")
+		return
+	}
+
+	if _, err := memj.Insert("TestCollection", map[string]interface{}{"Price": 1}); err != nil {
+		t.Error("Error inserting after DropAll: ", err)
+		return
+	}
+}
+
+func TestCollectionStats(t *testing.T) {
+	memj, _ := New()
+
+	_, err := memj.CollectionStats("Orders")
+	if err != ErrNotFound {
+		t.Error("Expected ErrNotFound for missing collection")
+		return
+	}
+
+	memj.Insert("Orders", map[string]interface{}{"Name": "abc", "Tags": []interface{}{"x"}})
+	memj.Insert("Orders", map[string]interface{}{"Name": "abcdef", "Price": 2.5})
+
+	stats, err := memj.CollectionStats("Orders")
+	if err != nil {
+		t.Error("Error getting stats: ", err)
+		return
+	}
+
+	if stats.Name != "Orders" || stats.Count != 2 || stats.HasValidator || len(stats.IndexSizes) != 0 {
+		t.Errorf("Incorrect stats: %+v", stats)
+		return
+	}
+
+	// document header 5, every field 2 + key length + value size, objectid
+	// value is 36 character string
+	objectID := int64(2 + 8 + 5 + 36)
+	first := 5 + (2 + 4 + 5 + 3) + (2 + 4 + 5 + (2 + 1 + 5 + 1)) + objectID
+	second := 5 + (2 + 4 + 5 + 6) + (2 + 5 + 8) + objectID
+	expected := first + second
+	if stats.Size != expected || stats.AvgObjSize != expected/2 {
+		t.Errorf("Incorrect size, expected %d: %+v", expected, stats)
+		return
+	}
+}
+
+func TestDropWhileWriting(t *testing.T) {
+	memj, _ := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				memj.Insert("Orders", map[string]interface{}{"OrderID": j})
+				memj.Count("Orders", nil)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			memj.DropCollection("Orders")
+			memj.Truncate("Orders")
+		}
+	}()
+	wg.Wait()
+
+	memj.DropAll()
+	if len(memj.ListCollections()) != 0 {
+		t.Error("Collections left after DropAll")
+		return
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	memj, _ := New()
+	insertOrders(t, memj, 10)
+
+	_, unlock, _ := memj.lockCollection(context.Background(), "TestCollection")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := memj.QueryCtx(ctx, "TestCollection", map[string]interface{}{"OrderPrice": 1}, NoLimit)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected DeadlineExceeded waiting for read lock, got ", err)
+		return
+	}
+
+	_, err = memj.InsertCtx(ctx, "TestCollection", map[string]interface{}{"OrderID": "new"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected DeadlineExceeded waiting for write lock, got ", err)
+		return
+	}
+
+	unlock()
+
+	if count, _ := memj.Count("TestCollection", nil); count != 10 {
+		t.Error("Insert stored after timeout: ", count)
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := memj.InsertCtx(ctx, "TestCollection", map[string]interface{}{"OrderID": "new"}); err != nil {
+		t.Error("Error inserting after lock released: ", err)
+		return
+	}
+}
+
+func TestLockTimeoutReleasesLocks(t *testing.T) {
+	memj, _ := New()
+	memj.Insert("Customers", map[string]interface{}{"Name": "Rob"})
+	memj.Insert("Orders", map[string]interface{}{"OrderID": "id-1"})
+
+	// Customers is locked first and must be released when Orders times out
+	_, unlock, _ := memj.lockCollection(context.Background(), "Orders")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := memj.AggregateCtx(ctx, "Customers", []interface{}{
+		map[string]interface{}{LOOKUP: map[string]interface{}{
+			"from": "Orders", "localField": "Name", "foreignField": "OrderID", "as": "Orders",
+		}},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected DeadlineExceeded, got ", err)
+		unlock()
+		return
+	}
+	unlock()
+
+	if _, err := memj.Insert("Customers", map[string]interface{}{"Name": "Ann"}); err != nil {
+		t.Error("Error inserting after timeout: ", err)
+		return
+	}
+}
+
+func TestCancelledScan(t *testing.T) {
+	memj, _ := New()
+	insertOrders(t, memj, 200)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, unlock, _ := memj.rLockCollection(context.Background(), "TestCollection")
+	_, err := memj.matchDocuments(ctx, c.documents, map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 0}}, NoLimit)
+	unlock()
+	if !errors.Is(err, context.Canceled) {
+		t.Error("Expected Canceled from scan, got ", err)
+		return
+	}
+
+	if _, err := memj.CountCtx(ctx, "TestCollection", nil); !errors.Is(err, context.Canceled) {
+		t.Error("Expected Canceled from CountCtx, got ", err)
+		return
+	}
+}
+
+func TestCreateCollection(t *testing.T) {
+	memj, _ := New()
+
+	err := memj.CreateCollection("Orders", CollectionOptions{
+		Validator: map[string]interface{}{"required": []interface{}{"Price"}},
+	})
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	collections := memj.ListCollections()
+	if len(collections) != 1 || collections[0] != "Orders" {
+		t.Error("Created collection not listed: ", collections)
+		return
+	}
+
+	if _, err := memj.Insert("Orders", map[string]interface{}{"Name": "Platypus"}); err == nil {
+		t.Error("Validator of created collection not applied")
+		return
+	}
+
+	if err := memj.CreateCollection("Orders", CollectionOptions{}); err != ErrCollectionExists {
+		t.Error("Expected ErrCollectionExists, got ", err)
+		return
+	}
+
+	err = memj.CreateCollection("Invalid", CollectionOptions{Validator: map[string]interface{}{"type": "unknown"}})
+	if err == nil {
+		t.Error("Expected error for invalid validator")
+		return
+	}
+	if len(memj.ListCollections()) != 1 {
+		t.Error("Collection created with invalid validator")
+		return
+	}
+}
@@ -0,0 +1,289 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PreparedQuery - query validated and compiled by Compile into tree of
+// matchers, documents are matched without interpreting the query again
+//
+// Prepared query is not bound to collection and is safe for concurrent use.
+// It matches the same documents as the query it was compiled from, invalid
+// operators are reported by Compile instead of by the first match.
+type PreparedQuery struct {
+	match documentMatcher
+}
+
+// documentMatcher - compiled query matching document
+type documentMatcher func(document map[string]interface{}) (bool, error)
+
+// valueMatcher - compiled operator expression matching value of field, found
+// is false for missing field and true for fields set to null
+type valueMatcher func(value interface{}, found bool) (bool, error)
+
+// Compile - validate query and compile it for matching many documents, pass
+// it to QueryPrepared or QueryAndUpdatePrepared to run the same query
+// repeatedly
+func (m *MemJ) Compile(query map[string]interface{}) (*PreparedQuery, error) {
+	match, err := m.compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreparedQuery{match: match}, nil
+}
+
+// Match - check if document matches prepared query
+func (q *PreparedQuery) Match(document map[string]interface{}) (bool, error) {
+	return q.match(document)
+}
+
+// QueryPrepared - query documents in collection with prepared query, see
+// Query
+func (m *MemJ) QueryPrepared(collection string, query *PreparedQuery, limit int) ([]map[string]interface{}, error) {
+	return m.QueryPreparedCtx(context.Background(), collection, query, limit)
+}
+
+// QueryPreparedCtx - like QueryPrepared but takes context
+func (m *MemJ) QueryPreparedCtx(ctx context.Context, collection string, query *PreparedQuery, limit int) (results []map[string]interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("Query", collection, start, len(results), err) }()
+
+	return m.queryPrepared(ctx, collection, query, limit)
+}
+
+// QueryAndUpdatePrepared - update documents in collection selected by
+// prepared query, see QueryAndUpdate
+func (m *MemJ) QueryAndUpdatePrepared(collection string, query *PreparedQuery, payload map[string]interface{}, limit int) ([]map[string]interface{}, bool, error) {
+	return m.QueryAndUpdatePreparedCtx(context.Background(), collection, query, payload, limit)
+}
+
+// QueryAndUpdatePreparedCtx - like QueryAndUpdatePrepared but takes context
+func (m *MemJ) QueryAndUpdatePreparedCtx(ctx context.Context, collection string, query *PreparedQuery, payload map[string]interface{}, limit int) (results []map[string]interface{}, isUpdated bool, err error) {
+	start := m.now()
+	defer func() { m.observe("QueryAndUpdate", collection, start, len(results), err) }()
+
+	return m.queryAndUpdatePrepared(ctx, collection, query, payload, limit)
+}
+
+// compileFilter - prepared query, nil for nil query selecting all documents
+func (m *MemJ) compileFilter(query map[string]interface{}) (*PreparedQuery, error) {
+	if query == nil {
+		return nil, nil
+	}
+	return m.Compile(query)
+}
+
+// compileQuery - matcher of all fields and logical operators of query, empty
+// query matches no documents
+func (m *MemJ) compileQuery(query map[string]interface{}) (documentMatcher, error) {
+	if len(query) == 0 {
+		return func(map[string]interface{}) (bool, error) { return false, nil }, nil
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	matchers := make([]documentMatcher, 0, len(keys))
+	for _, k := range keys {
+		match, err := m.compileField(k, query[k])
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, match)
+	}
+
+	if len(matchers) == 1 {
+		return matchers[0], nil
+	}
+
+	return func(document map[string]interface{}) (bool, error) {
+		for _, match := range matchers {
+			isFound, err := match(document)
+			if err != nil || !isFound {
+				return false, err
+			}
+		}
+		return true, nil
+	}, nil
+}
+
+// compileField - matcher of value or operator expression of field at dotted
+// key, or of logical operator
+func (m *MemJ) compileField(k string, value interface{}) (documentMatcher, error) {
+	if m.isLogicalOperator(k) {
+		queryList, ok := value.([]interface{})
+		if !ok {
+			return nil, errors.New("Logical operator query has invalid syntax.  Expected a list of queries.")
+		}
+		return m.compileLogicalOp(k, queryList)
+	}
+
+	key := strings.Split(k, ".")
+	op, operand, isOperator, err := m.isComparisonOperator(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isOperator {
+		return func(document map[string]interface{}) (bool, error) {
+			return m.valuesEqual(value, m.getNestedQueryValue(key, document)), nil
+		}, nil
+	}
+
+	match, err := m.compileValueOp(op, operand)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(document map[string]interface{}) (bool, error) {
+		docValue, found := m.lookupNestedValue(key, document)
+		return match(docValue, found)
+	}, nil
+}
+
+// compileLogicalOp - matcher of $and or $or of queries, all queries are
+// matched so errors of any of them are reported
+func (m *MemJ) compileLogicalOp(operator string, queryList []interface{}) (documentMatcher, error) {
+	matchers := make([]documentMatcher, len(queryList))
+	for i, query := range queryList {
+		queryMap, ok := query.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("Logical operator query has invalid syntax.  Expected a list of queries.")
+		}
+		match, err := m.compileQuery(queryMap)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = match
+	}
+
+	return func(document map[string]interface{}) (bool, error) {
+		all, any := true, false
+		for _, match := range matchers {
+			isFound, err := match(document)
+			if err != nil {
+				return false, err
+			}
+			all = all && isFound
+			any = any || isFound
+		}
+
+		if operator == OR {
+			return any, nil
+		}
+		return all, nil
+	}, nil
+}
+
+// compileValueOp - matcher of operator with operand validated by
+// isComparisonOperator
+func (m *MemJ) compileValueOp(op string, operand interface{}) (valueMatcher, error) {
+	switch op {
+	case IN, NIN:
+		values := operand.([]interface{})
+		return func(docValue interface{}, _ bool) (bool, error) {
+			isFound := false
+			for _, v := range values {
+				if m.valuesEqual(v, docValue) || m.listContains(docValue, v) {
+					isFound = true
+					break
+				}
+			}
+			return isFound == (op == IN), nil
+		}, nil
+
+	case REGEX:
+		re := operand.(*regexp.Regexp)
+		return func(docValue interface{}, _ bool) (bool, error) {
+			str, ok := docValue.(string)
+			if !ok {
+				return false, nil
+			}
+			return re.MatchString(str), nil
+		}, nil
+
+	case NOT:
+		notOp, notOperand, _, err := m.isComparisonOperator(operand)
+		if err != nil {
+			return nil, err
+		}
+		match, err := m.compileValueOp(notOp, notOperand)
+		if err != nil {
+			return nil, err
+		}
+		return func(docValue interface{}, found bool) (bool, error) {
+			isFound, err := match(docValue, found)
+			if err != nil && docValue == nil {
+				// missing field cannot be compared, so it does not match
+				return true, nil
+			}
+			return !isFound, err
+		}, nil
+
+	case ELEMMATCH:
+		return m.compileElemMatch(operand.(map[string]interface{}))
+
+	case EXISTS:
+		exists := operand.(bool)
+		return func(_ interface{}, found bool) (bool, error) {
+			return found == exists, nil
+		}, nil
+	}
+
+	return func(docValue interface{}, _ bool) (bool, error) {
+		return m.performComperisonOp(op, docValue, operand)
+	}, nil
+}
+
+// compileElemMatch - matcher of arrays with element matching operator
+// expression, or with document element matching query
+func (m *MemJ) compileElemMatch(query map[string]interface{}) (valueMatcher, error) {
+	elemOp, elemOperand, isOperator, err := m.isComparisonOperator(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var match valueMatcher
+	if isOperator {
+		match, err = m.compileValueOp(elemOp, elemOperand)
+	} else {
+		var matchDocument documentMatcher
+		matchDocument, err = m.compileQuery(query)
+		match = func(elem interface{}, _ bool) (bool, error) {
+			elemDoc, ok := elem.(map[string]interface{})
+			if !ok {
+				return false, nil
+			}
+			return matchDocument(elemDoc)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}, _ bool) (bool, error) {
+		list, ok := value.([]interface{})
+		if !ok {
+			return false, nil
+		}
+
+		for _, elem := range list {
+			isFound, err := match(elem, true)
+			if err != nil {
+				return false, err
+			}
+			if isFound {
+				return true, nil
+			}
+		}
+		return false, nil
+	}, nil
+}
@@ -0,0 +1,114 @@
+package memj
+
+import (
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	memj, _ := New()
+
+	invalid := []map[string]interface{}{
+		{"$or": "invalid"},
+		{"Name": map[string]interface{}{REGEX: "("}},
+		{"Price": map[string]interface{}{GT: true}},
+		{"Items": map[string]interface{}{ELEMMATCH: map[string]interface{}{"$and": 1}}},
+		{"Price": map[string]interface{}{NOT: map[string]interface{}{IN: 1}}},
+		{"$or": []interface{}{map[string]interface{}{"Name": "a"}, "b"}},
+	}
+	for _, query := range invalid {
+		if _, err := memj.Compile(query); err == nil {
+			t.Error("Expected error compiling ", query)
+			return
+		}
+	}
+
+	document := map[string]interface{}{
+		"Name":  "FindMe",
+		"Order": map[string]interface{}{"Price": 10, "Items": []interface{}{map[string]interface{}{"Sku": "a"}}},
+		"Sizes": []interface{}{1, 3},
+		"Note":  nil,
+		"Tags":  []interface{}{nil},
+	}
+	tests := []struct {
+		query    map[string]interface{}
+		expected bool
+	}{
+		{map[string]interface{}{}, false},
+		{map[string]interface{}{"$and": []interface{}{}}, true},
+		{map[string]interface{}{"Name": "FindMe", "Order.Price": 10}, true},
+		{map[string]interface{}{"Name": "FindMe", "Order.Price": 11}, false},
+		{map[string]interface{}{"Name": map[string]interface{}{NOT: map[string]interface{}{REGEX: "^Find"}}}, false},
+		{map[string]interface{}{"Missing": map[string]interface{}{NOT: map[string]interface{}{EQ: 1}}}, true},
+		{map[string]interface{}{"Missing": map[string]interface{}{NOT: map[string]interface{}{EXISTS: false}}}, false},
+		{map[string]interface{}{"Name": map[string]interface{}{NOT: map[string]interface{}{EXISTS: false}}}, true},
+		{map[string]interface{}{"Order.Discount": map[string]interface{}{EXISTS: false}}, true},
+		{map[string]interface{}{"Note": map[string]interface{}{EXISTS: true}}, true},
+		{map[string]interface{}{"Note": map[string]interface{}{NOT: map[string]interface{}{EXISTS: false}}}, true},
+		{map[string]interface{}{"Note": map[string]interface{}{NOT: map[string]interface{}{EXISTS: true}}}, false},
+		{map[string]interface{}{"Tags": map[string]interface{}{ELEMMATCH: map[string]interface{}{EXISTS: true}}}, true},
+		{map[string]interface{}{"Order.Items": map[string]interface{}{ELEMMATCH: map[string]interface{}{"Sku": "a"}}}, true},
+		{map[string]interface{}{"Sizes": map[string]interface{}{ELEMMATCH: map[string]interface{}{GT: 2}}}, true},
+		{map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"Name": "Other"},
+			map[string]interface{}{"Order.Price": map[string]interface{}{IN: []interface{}{5, 10}}},
+		}}, true},
+	}
+	for _, test := range tests {
+		prepared, err := memj.Compile(test.query)
+		if err != nil {
+			t.Error("Error compiling ", test.query, ": ", err)
+			return
+		}
+		isFound, err := prepared.Match(document)
+		if err != nil || isFound != test.expected {
+			t.Error("Expected ", test.expected, " matching ", test.query, ", got ", isFound, err)
+			return
+		}
+	}
+
+	prepared, _ := memj.Compile(map[string]interface{}{"Name": map[string]interface{}{GT: "a"}})
+	if _, err := prepared.Match(map[string]interface{}{"Name": 1}); err == nil {
+		t.Error("Expected error comparing values of different types")
+		return
+	}
+}
+
+func TestQueryPrepared(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 10) {
+		return
+	}
+
+	prepared, err := memj.Compile(map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 5}})
+	if err != nil {
+		t.Error("Error compiling: ", err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		results, err := memj.QueryPrepared("TestCollection", prepared, 2)
+		if err != nil || len(results) != 2 || results[0]["OrderPrice"] != float64(5) {
+			t.Error("Expected first 2 matches, got ", results, err)
+			return
+		}
+	}
+
+	results, isUpdated, err := memj.QueryAndUpdatePrepared("TestCollection", prepared, map[string]interface{}{"Shipped": true}, NoLimit)
+	if err != nil || !isUpdated || len(results) != 5 || results[0]["Shipped"] != true {
+		t.Error("Expected 5 documents updated, got ", results, err)
+		return
+	}
+	if count, _ := memj.Count("TestCollection", map[string]interface{}{"Shipped": true}); count != 5 {
+		t.Error("Expected 5 shipped documents, got ", count)
+		return
+	}
+
+	if _, err := memj.Query("TestCollection", map[string]interface{}{"$and": "invalid"}, NoLimit); err == nil {
+		t.Error("Expected Query to report invalid query")
+		return
+	}
+	if _, _, err := memj.QueryAndUpdate("TestCollection", map[string]interface{}{"$and": "invalid"}, map[string]interface{}{}, NoLimit); err == nil {
+		t.Error("Expected QueryAndUpdate to report invalid query")
+		return
+	}
+}
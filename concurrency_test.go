@@ -0,0 +1,179 @@
+package memj
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Stress tests for concurrent use of collections, run them with -race.
+
+func TestConcurrentCollectionCreation(t *testing.T) {
+	memj, _ := New()
+
+	const goroutines = 16
+	const collections = 50
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			for j := 0; j < collections; j++ {
+				_, err := memj.Insert(fmt.Sprintf("Collection%d", j), map[string]interface{}{"Writer": i})
+				if err != nil {
+					t.Error("Error inserting document: ", err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-start
+		for j := 0; j < collections; j++ {
+			memj.ListCollections()
+			memj.Count(fmt.Sprintf("Collection%d", j), nil)
+		}
+	}()
+
+	close(start)
+	wg.Wait()
+
+	if len(memj.ListCollections()) != collections {
+		t.Error("Incorrect number of collections: ", len(memj.ListCollections()))
+		return
+	}
+
+	for j := 0; j < collections; j++ {
+		count, _ := memj.Count(fmt.Sprintf("Collection%d", j), nil)
+		if count != goroutines {
+			t.Errorf("Lost inserts in Collection%d, %d documents", j, count)
+			return
+		}
+	}
+}
+
+func TestConcurrentReadersWriters(t *testing.T) {
+	memj, _ := New()
+	insertOrders(t, memj, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			query := map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 50}}
+			for j := 0; j < 100; j++ {
+				switch (i + j) % 5 {
+				case 0:
+					memj.Insert("TestCollection", map[string]interface{}{"OrderID": "new", "OrderPrice": j})
+
+				case 1:
+					memj.QueryAndUpdate("TestCollection", query, map[string]interface{}{"Touched": j}, FindOne)
+
+				case 2:
+					memj.Query("TestCollection", query, NoLimit)
+
+				case 3:
+					cursor, _ := memj.QueryCursor("TestCollection", query, CursorOptions{BatchSize: 7})
+					for cursor.Next() {
+					}
+					cursor.Close()
+
+				case 4:
+					memj.Distinct("TestCollection", "OrderID", nil)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	count, _ := memj.Count("TestCollection", nil)
+	if count != 100+8*20 {
+		t.Error("Incorrect number of documents: ", count)
+		return
+	}
+}
+
+func TestConcurrentDropRename(t *testing.T) {
+	memj, _ := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				memj.Insert("Orders", map[string]interface{}{"OrderID": j})
+				memj.Insert("Customers", map[string]interface{}{"Name": j})
+				memj.Aggregate("Orders", []interface{}{
+					map[string]interface{}{LIMIT: float64(5)},
+					map[string]interface{}{LOOKUP: map[string]interface{}{
+						"from": "Customers", "localField": "OrderID", "foreignField": "Name", "as": "Customer",
+					}},
+				})
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			memj.RenameCollection("Orders", "Archive")
+			memj.DropCollection("Archive")
+			memj.Truncate("Customers")
+			if j%10 == 0 {
+				memj.DropAll()
+			}
+		}
+	}()
+	wg.Wait()
+
+	memj.DropAll()
+	if len(memj.ListCollections()) != 0 || len(memj.collections) != 0 {
+		t.Error("Collections left after DropAll")
+		return
+	}
+}
+
+func TestConcurrentInsertDuringDrop(t *testing.T) {
+	memj, _ := New()
+
+	const inserts = 500
+
+	var wg sync.WaitGroup
+	var dropped sync.Mutex
+	removed := 0
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < inserts; j++ {
+			memj.Insert("Orders", map[string]interface{}{"OrderID": j})
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			n, _ := memj.Truncate("Orders")
+			dropped.Lock()
+			removed += n
+			dropped.Unlock()
+		}
+	}()
+	wg.Wait()
+
+	count, _ := memj.Count("Orders", nil)
+	if count+removed != inserts {
+		t.Errorf("Inserts lost, %d remaining and %d truncated of %d", count, removed, inserts)
+		return
+	}
+}
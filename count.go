@@ -0,0 +1,211 @@
+package memj
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Count - count documents in collection matching query, nil query counts all documents
+func (m *MemJ) Count(collection string, query map[string]interface{}) (int, error) {
+	return m.CountCtx(context.Background(), collection, query)
+}
+
+// CountCtx - like Count but takes context
+func (m *MemJ) CountCtx(ctx context.Context, collection string, query map[string]interface{}) (count int, err error) {
+	start := m.now()
+	defer func() { m.observe("Count", collection, start, count, err) }()
+
+	prepared, err := m.compileFilter(query)
+	if err != nil {
+		return 0, err
+	}
+
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.countVersion(ctx, c, prepared)
+}
+
+// countDocuments - count documents matching prepared query, nil query counts
+// all
+func (m *MemJ) countDocuments(ctx context.Context, documents []map[string]interface{}, query *PreparedQuery) (int, error) {
+	if query == nil {
+		return len(documents), nil
+	}
+
+	count := 0
+	for index, value := range documents {
+		if err := checkContext(ctx, index); err != nil {
+			return 0, err
+		}
+
+		isFound, err := query.Match(value)
+		if err != nil {
+			return 0, err
+		}
+
+		if isFound {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Exists - check if any document in collection matches query
+func (m *MemJ) Exists(collection string, query map[string]interface{}) (bool, error) {
+	return m.ExistsCtx(context.Background(), collection, query)
+}
+
+// ExistsCtx - like Exists but takes context
+func (m *MemJ) ExistsCtx(ctx context.Context, collection string, query map[string]interface{}) (exists bool, err error) {
+	start := m.now()
+	defer func() { m.observe("Exists", collection, start, boolCount(exists), err) }()
+
+	prepared, err := m.compileFilter(query)
+	if err != nil {
+		return false, err
+	}
+
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return false, err
+	}
+
+	documents := c.all()
+	if prepared == nil {
+		return len(documents) > 0, nil
+	}
+
+	for index, value := range documents {
+		if err := checkContext(ctx, index); err != nil {
+			return false, err
+		}
+
+		isFound, err := prepared.Match(value)
+		if err != nil {
+			return false, err
+		}
+
+		if isFound {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Distinct - return distinct values of field at dotted path in documents matching
+// query, nil query selects all documents
+//
+// Arrays found along the path are flattened, so each element of an array value
+// is treated as a separate value.  Values are returned in order of first occurrence.
+func (m *MemJ) Distinct(collection, path string, query map[string]interface{}) ([]interface{}, error) {
+	return m.DistinctCtx(context.Background(), collection, path, query)
+}
+
+// DistinctCtx - like Distinct but takes context
+func (m *MemJ) DistinctCtx(ctx context.Context, collection, path string, query map[string]interface{}) (values []interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("Distinct", collection, start, len(values), err) }()
+
+	prepared, err := m.compileFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := strings.Split(path, ".")
+	seen := make(map[interface{}]bool)
+	values = []interface{}{}
+
+	for index, value := range c.all() {
+		if err := checkContext(ctx, index); err != nil {
+			return nil, err
+		}
+
+		if prepared != nil {
+			isFound, err := prepared.Match(value)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isFound {
+				continue
+			}
+		}
+
+		for _, v := range m.getFlattenedValues(keys, value) {
+			key := m.distinctKey(v)
+			if !seen[key] {
+				seen[key] = true
+				if m.copyOnRead {
+					v = m.copyValue(v)
+				}
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// getFlattenedValues - return all values at path descending into arrays
+func (m *MemJ) getFlattenedValues(nestedKeys []string, documentLevel interface{}) []interface{} {
+	if list, ok := documentLevel.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range list {
+			values = append(values, m.getFlattenedValues(nestedKeys, item)...)
+		}
+		return values
+	}
+
+	if len(nestedKeys) == 0 {
+		return []interface{}{documentLevel}
+	}
+
+	currentDocument, ok := documentLevel.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	value, ok := currentDocument[nestedKeys[0]]
+	if !ok {
+		return nil
+	}
+
+	return m.getFlattenedValues(nestedKeys[1:], value)
+}
+
+// distinctKey - hashable key identifying value, numbers equal by value share
+// key whatever their type and values that cannot be map keys are keyed by
+// their json encoding
+func (m *MemJ) distinctKey(value interface{}) interface{} {
+	if i, f, isInt, isNumber := numberValue(value); isNumber {
+		if isInt {
+			return i
+		}
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f)
+		}
+		return f
+	}
+
+	if value == nil || reflect.TypeOf(value).Comparable() {
+		return value
+	}
+
+	encoded, _ := json.Marshal(value)
+	return encodedKey(encoded)
+}
+
+type encodedKey string
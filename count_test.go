@@ -0,0 +1,197 @@
+package memj
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func insertTaggedOrders(t *testing.T, memj *MemJ) bool {
+	for i := 0; i < 100; i++ {
+		payloadText := fmt.Sprintf(`{"OrderID": %d, "Customer": {"Region": "region-%d"}, "Tags": ["tag-%d", "common"]}`, i, i%4, i%3)
+		var payload map[string]interface{}
+		err := json.Unmarshal([]byte(payloadText), &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return false
+		}
+
+		_, err = memj.Insert("TestCollection", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestCount(t *testing.T) {
+	memj, _ := New()
+	if !insertTaggedOrders(t, memj) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderID": {"$gte": 90}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	count, err := memj.Count("TestCollection", queryPayload)
+
+	if err != nil {
+		t.Error("Error in Count: ", err)
+		return
+	}
+
+	if count != 10 {
+		t.Error("Incorrect count returned")
+		return
+	}
+
+	count, err = memj.Count("TestCollection", nil)
+
+	if err != nil {
+		t.Error("Error in Count: ", err)
+		return
+	}
+
+	if count != 100 {
+		t.Error("Incorrect count of all documents returned")
+		return
+	}
+}
+
+func TestCountInvalidQuery(t *testing.T) {
+	memj, _ := New()
+	if !insertTaggedOrders(t, memj) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderID": {"$gte": "90"}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	_, err = memj.Count("TestCollection", queryPayload)
+
+	if err == nil {
+		t.Error("Incorrect types in comparison but no error")
+		return
+	}
+}
+
+func TestExists(t *testing.T) {
+	memj, _ := New()
+	if !insertTaggedOrders(t, memj) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"Customer.Region": "region-3"}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	exists, err := memj.Exists("TestCollection", queryPayload)
+
+	if err != nil {
+		t.Error("Error in Exists: ", err)
+		return
+	}
+
+	if !exists {
+		t.Error("Document should exist")
+		return
+	}
+
+	queryPayload["Customer.Region"] = "region-4"
+	exists, err = memj.Exists("TestCollection", queryPayload)
+
+	if err != nil {
+		t.Error("Error in Exists: ", err)
+		return
+	}
+
+	if exists {
+		t.Error("Document should not exist")
+		return
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	memj, _ := New()
+	if !insertTaggedOrders(t, memj) {
+		return
+	}
+
+	values, err := memj.Distinct("TestCollection", "Customer.Region", nil)
+
+	if err != nil {
+		t.Error("Error in Distinct: ", err)
+		return
+	}
+
+	if len(values) != 4 {
+		t.Error("Incorrect number of distinct values returned")
+		return
+	}
+
+	if values[0] != "region-0" || values[3] != "region-3" {
+		t.Error("Distinct values not in order of first occurrence")
+		return
+	}
+}
+
+func TestDistinctMixedNumbers(t *testing.T) {
+	memj, _ := New()
+	memj.InsertJSON("TestCollection", `{"Quantity": 1}`)
+	memj.Insert("TestCollection", map[string]interface{}{"Quantity": float64(1)})
+	memj.Insert("TestCollection", map[string]interface{}{"Quantity": 1.5})
+
+	values, err := memj.Distinct("TestCollection", "Quantity", nil)
+	if err != nil || len(values) != 2 || values[0] != int64(1) || values[1] != 1.5 {
+		t.Error("Expected numbers equal by value to be one distinct value, got ", values, err)
+		return
+	}
+}
+
+func TestDistinctFlattensArrays(t *testing.T) {
+	memj, _ := New()
+	if !insertTaggedOrders(t, memj) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderID": {"$lt": 2}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	values, err := memj.Distinct("TestCollection", "Tags", queryPayload)
+
+	if err != nil {
+		t.Error("Error in Distinct: ", err)
+		return
+	}
+
+	if len(values) != 3 {
+		t.Error("Incorrect number of distinct values returned")
+		return
+	}
+}
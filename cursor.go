@@ -0,0 +1,279 @@
+package memj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultBatchSize - number of documents matched per batch when not specified
+const DefaultBatchSize = 100
+
+// CursorOptions - options for QueryCursor
+type CursorOptions struct {
+	// BatchSize - number of matching documents collected each time the
+	// cursor fetches its next batch, DefaultBatchSize when zero
+	BatchSize int
+	// Limit - maximum number of documents returned, NoLimit when zero
+	Limit int
+	// Tailable - cursor over capped collection that also returns documents
+	// inserted after it was opened, Next waits for them until the context of
+	// QueryCursorCtx is done
+	Tailable bool
+}
+
+// Cursor - lazily evaluated query result
+//
+// When a cursor is opened it takes a version of the collection and returns
+// documents as they were at that time, later inserts, updates and deletes are
+// not seen.  The query is evaluated batch by batch without locking the
+// collection, documents expiring while the cursor is read are skipped.  A
+// cursor opened with QueryCursorCtx stops with the context error once the
+// context is done.
+//
+// A tailable cursor instead reads the capped collection in insertion order
+// and keeps returning documents as they are inserted.  It fails with
+// ErrCappedPositionLost when documents it has not read were evicted and when
+// the collection is dropped.
+type Cursor struct {
+	ctx        context.Context
+	m          *MemJ
+	collection string
+	query      *PreparedQuery
+	options    CursorOptions
+	// version - collection read by cursor that is not tailable
+	version  *collection
+	position int
+	batch    []map[string]interface{}
+	current  map[string]interface{}
+	returned int
+	err      error
+	closed   bool
+	// tailed - collection read by tailable cursor
+	tailed *collection
+	// next - sequence number of next document read by tailable cursor
+	next uint64
+}
+
+// QueryCursor - open cursor over documents in collection matching query
+func (m *MemJ) QueryCursor(collection string, query map[string]interface{}, options CursorOptions) (*Cursor, error) {
+	return m.QueryCursorCtx(context.Background(), collection, query, options)
+}
+
+// QueryCursorCtx - like QueryCursor but takes context
+func (m *MemJ) QueryCursorCtx(ctx context.Context, collection string, query map[string]interface{}, options CursorOptions) (*Cursor, error) {
+	if options.BatchSize < 0 || options.Limit < 0 {
+		return nil, errors.New("Batch size and limit must not be negative")
+	}
+	if options.BatchSize == 0 {
+		options.BatchSize = DefaultBatchSize
+	}
+
+	prepared, err := m.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Tailable {
+		return m.tailCursor(ctx, collection, prepared, options)
+	}
+
+	version, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{
+		ctx:        ctx,
+		m:          m,
+		collection: collection,
+		query:      prepared,
+		options:    options,
+		version:    version,
+	}, nil
+}
+
+// tailCursor - open tailable cursor over capped collection
+func (m *MemJ) tailCursor(ctx context.Context, collection string, query *PreparedQuery, options CursorOptions) (*Cursor, error) {
+	coll, unlock, err := m.rLockCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if coll.capped == nil {
+		return nil, errors.New("Tailable cursor requires capped collection")
+	}
+
+	return &Cursor{
+		ctx:        ctx,
+		m:          m,
+		collection: collection,
+		query:      query,
+		options:    options,
+		tailed:     coll,
+		next:       coll.first,
+	}, nil
+}
+
+// Next - advance cursor to next document, false when cursor is exhausted or failed
+func (c *Cursor) Next() bool {
+	c.current = nil
+	if c.closed || c.err != nil {
+		return false
+	}
+
+	if c.options.Limit != NoLimit && c.returned >= c.options.Limit {
+		return false
+	}
+
+	if len(c.batch) == 0 {
+		if c.tailed != nil {
+			c.err = c.waitBatch()
+		} else {
+			c.err = c.fetchBatch()
+		}
+		if c.err != nil || len(c.batch) == 0 {
+			return false
+		}
+	}
+
+	c.current = c.batch[0]
+	c.batch = c.batch[1:]
+	c.returned++
+
+	return true
+}
+
+func (c *Cursor) fetchBatch() error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+
+	var now time.Time
+	if c.version.ttl != nil {
+		now = c.m.now()
+	}
+
+	for c.position < len(c.version.all()) && len(c.batch) < c.options.BatchSize {
+		if err := checkContext(c.ctx, c.position); err != nil {
+			return err
+		}
+
+		document := c.version.all()[c.position]
+		c.position++
+
+		if c.m.expired(c.version, document, now) {
+			continue
+		}
+
+		isFound, err := c.query.Match(document)
+		if err != nil {
+			return err
+		}
+
+		if isFound {
+			c.batch = append(c.batch, c.m.readDocument(document))
+		}
+	}
+
+	return nil
+}
+
+// waitBatch - fetch batch of tailable cursor waiting for inserted documents
+// when there are none
+func (c *Cursor) waitBatch() error {
+	for {
+		changed, err := c.fetchTailBatch()
+		if err != nil || len(c.batch) > 0 {
+			return err
+		}
+
+		select {
+		case <-changed:
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+}
+
+// fetchTailBatch - match documents appended since last batch, returns channel
+// closed on next change of collection
+func (c *Cursor) fetchTailBatch() (<-chan struct{}, error) {
+	coll, unlock, err := c.m.rLockCollection(c.ctx, c.collection)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if coll != c.tailed {
+		return nil, errors.New("Collection of tailable cursor was dropped")
+	}
+	if c.next < coll.first {
+		return nil, ErrCappedPositionLost
+	}
+
+	for scanned := 0; c.next-coll.first < uint64(len(coll.documents)) && len(c.batch) < c.options.BatchSize; scanned++ {
+		if err := checkContext(c.ctx, scanned); err != nil {
+			return nil, err
+		}
+
+		document := coll.documents[c.next-coll.first]
+		c.next++
+
+		isFound, err := c.query.Match(document)
+		if err != nil {
+			return nil, err
+		}
+
+		if isFound {
+			c.batch = append(c.batch, c.m.readDocument(document))
+		}
+	}
+
+	return coll.changed, nil
+}
+
+// Document - current document
+func (c *Cursor) Document() map[string]interface{} {
+	return c.current
+}
+
+// Decode - decode current document into value
+//
+// A *map[string]interface{} receives the stored document, any other value is
+// filled using its json encoding.
+func (c *Cursor) Decode(value interface{}) error {
+	if c.current == nil {
+		return errors.New("No current document")
+	}
+
+	if document, ok := value.(*map[string]interface{}); ok {
+		*document = c.current
+		return nil
+	}
+
+	encoded, err := json.Marshal(c.current)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, value)
+}
+
+// Err - error that stopped iteration
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close - release documents held by cursor
+func (c *Cursor) Close() error {
+	c.closed = true
+	c.current = nil
+	c.batch = nil
+	c.version = nil
+	c.tailed = nil
+
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package memj
+
+import (
+	"context"
+	"iter"
+)
+
+// QuerySeq - iterate over documents in collection matching query
+//
+// Documents are produced by a cursor opened with options, see Cursor for
+// snapshot semantics.  Iteration stops after yielding an error.
+func (m *MemJ) QuerySeq(collection string, query map[string]interface{}, options CursorOptions) iter.Seq2[map[string]interface{}, error] {
+	return m.QuerySeqCtx(context.Background(), collection, query, options)
+}
+
+// QuerySeqCtx - like QuerySeq but takes context
+func (m *MemJ) QuerySeqCtx(ctx context.Context, collection string, query map[string]interface{}, options CursorOptions) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		cursor, err := m.QueryCursorCtx(ctx, collection, query, options)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer cursor.Close()
+
+		for cursor.Next() {
+			if !yield(cursor.Document(), nil) {
+				return
+			}
+		}
+
+		if cursor.Err() != nil {
+			yield(nil, cursor.Err())
+		}
+	}
+}
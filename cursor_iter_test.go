@@ -0,0 +1,47 @@
+//go:build go1.23
+
+package memj
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestQuerySeq(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 100) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$lt": 30}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	count := 0
+	for document, err := range memj.QuerySeq("TestCollection", queryPayload, CursorOptions{BatchSize: 8}) {
+		if err != nil {
+			t.Error("Error in QuerySeq: ", err)
+			return
+		}
+
+		if document["OrderPrice"].(float64) >= 30 {
+			t.Error("Wrong document returned")
+			return
+		}
+
+		count++
+		if count == 20 {
+			break
+		}
+	}
+
+	if count != 20 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
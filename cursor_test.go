@@ -0,0 +1,254 @@
+package memj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func insertOrders(t *testing.T, memj *MemJ, count int) bool {
+	for i := 0; i < count; i++ {
+		payloadText := fmt.Sprintf(`{"OrderID": "id-%d", "OrderPrice": %d}`, i, i)
+		var payload map[string]interface{}
+		err := json.Unmarshal([]byte(payloadText), &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return false
+		}
+
+		_, err = memj.Insert("TestCollection", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestQueryCursor(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 100) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$gte": 50}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	cursor, err := memj.QueryCursor("TestCollection", queryPayload, CursorOptions{BatchSize: 7})
+
+	if err != nil {
+		t.Error("Error in QueryCursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		price := cursor.Document()["OrderPrice"].(float64)
+		if price != float64(50+count) {
+			t.Error("Documents returned out of order")
+			return
+		}
+		count++
+	}
+
+	if cursor.Err() != nil {
+		t.Error("Error in cursor: ", cursor.Err())
+		return
+	}
+
+	if count != 50 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestQueryCursorLimit(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 100) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$gte": 50}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	cursor, err := memj.QueryCursor("TestCollection", queryPayload, CursorOptions{BatchSize: 3, Limit: 5})
+
+	if err != nil {
+		t.Error("Error in QueryCursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		count++
+	}
+
+	if count != 5 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestQueryCursorDecode(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 10) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderID": "id-7"}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	cursor, err := memj.QueryCursor("TestCollection", queryPayload, CursorOptions{})
+
+	if err != nil {
+		t.Error("Error in QueryCursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	if !cursor.Next() {
+		t.Error("Document not found")
+		return
+	}
+
+	var order struct {
+		OrderID    string
+		OrderPrice int
+	}
+	err = cursor.Decode(&order)
+
+	if err != nil {
+		t.Error("Error in Decode: ", err)
+		return
+	}
+
+	if order.OrderID != "id-7" || order.OrderPrice != 7 {
+		t.Error("Document decoded incorrectly")
+		return
+	}
+}
+
+func TestQueryCursorIgnoresLaterInserts(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 10) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$gte": 0}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	cursor, err := memj.QueryCursor("TestCollection", queryPayload, CursorOptions{BatchSize: 2})
+
+	if err != nil {
+		t.Error("Error in QueryCursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		if count == 0 && !insertOrders(t, memj, 10) {
+			return
+		}
+		count++
+	}
+
+	if count != 10 {
+		t.Error("Documents inserted after cursor was opened were returned")
+		return
+	}
+}
+
+func TestQueryCursorError(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 10) {
+		return
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$gte": "5"}}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	cursor, err := memj.QueryCursor("TestCollection", queryPayload, CursorOptions{})
+
+	if err != nil {
+		t.Error("Error in QueryCursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	if cursor.Next() {
+		t.Error("Incorrect types in comparison but document returned")
+		return
+	}
+
+	if cursor.Err() == nil {
+		t.Error("Incorrect types in comparison but no error")
+		return
+	}
+}
+
+func TestQueryCursorCancel(t *testing.T) {
+	memj, _ := New()
+	if !insertOrders(t, memj, 100) {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cursor, err := memj.QueryCursorCtx(ctx, "TestCollection", map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 0}}, CursorOptions{BatchSize: 10})
+	if err != nil {
+		t.Error("Error opening cursor: ", err)
+		return
+	}
+	defer cursor.Close()
+
+	count := 0
+	for cursor.Next() {
+		count++
+		if count == 15 {
+			cancel()
+		}
+	}
+
+	if count != 20 || !errors.Is(cursor.Err(), context.Canceled) {
+		t.Error("Cursor not stopped by cancel: ", count, cursor.Err())
+		return
+	}
+}
@@ -0,0 +1,268 @@
+package memj
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// Filter - query built with Eq, Gt, In, And, Or and other filter constructors
+//
+// Operands are validated when a filter is constructed and the first error is
+// carried through enclosing filters and returned by Query.
+type Filter struct {
+	query map[string]interface{}
+	field string
+	expr  map[string]interface{}
+	err   error
+}
+
+// FieldValue - types that can be compared with Field filters
+type FieldValue interface {
+	~string | ~float64 | ~float32 |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// Field - field path whose comparison operands must be of type V
+//
+// For example Field[float64]("Order.Price").Gt(10) does not compile when
+// given a string operand.
+type Field[V FieldValue] string
+
+// Eq - field equals value
+func (f Field[V]) Eq(value V) Filter { return Eq(string(f), value) }
+
+// Ne - field does not equal value
+func (f Field[V]) Ne(value V) Filter { return Ne(string(f), value) }
+
+// Gt - field is greater than value
+func (f Field[V]) Gt(value V) Filter { return Gt(string(f), value) }
+
+// Gte - field is greater than or equal to value
+func (f Field[V]) Gte(value V) Filter { return Gte(string(f), value) }
+
+// Lt - field is less than value
+func (f Field[V]) Lt(value V) Filter { return Lt(string(f), value) }
+
+// Lte - field is less than or equal to value
+func (f Field[V]) Lte(value V) Filter { return Lte(string(f), value) }
+
+// In - field equals any of values
+func (f Field[V]) In(values ...V) Filter { return In(string(f), toInterfaces(values)...) }
+
+// Nin - field equals none of values
+func (f Field[V]) Nin(values ...V) Filter { return Nin(string(f), toInterfaces(values)...) }
+
+// Exists - field is present, or absent when exists is false
+func (f Field[V]) Exists(exists bool) Filter { return Exists(string(f), exists) }
+
+// Eq - field equals value
+func Eq(field string, value interface{}) Filter {
+	value, err := normalizeFilterValue(value)
+	if err != nil {
+		return Filter{err: err}
+	}
+
+	filter := fieldFilter(field, EQ, value)
+	filter.query = map[string]interface{}{field: value}
+
+	return filter
+}
+
+// Ne - field does not equal value
+func Ne(field string, value interface{}) Filter {
+	value, err := normalizeFilterValue(value)
+	if err != nil {
+		return Filter{err: err}
+	}
+
+	switch value.(type) {
+	case float64, int64, string, time.Time, bool, nil:
+		return fieldFilter(field, NE, value)
+	}
+
+	return Filter{err: errors.New("Invalid type for comparison")}
+}
+
+// Gt - field is greater than value
+func Gt(field string, value interface{}) Filter { return comparisonFilter(field, GT, value) }
+
+// Gte - field is greater than or equal to value
+func Gte(field string, value interface{}) Filter { return comparisonFilter(field, GTE, value) }
+
+// Lt - field is less than value
+func Lt(field string, value interface{}) Filter { return comparisonFilter(field, LT, value) }
+
+// Lte - field is less than or equal to value
+func Lte(field string, value interface{}) Filter { return comparisonFilter(field, LTE, value) }
+
+// In - field equals any of values
+func In(field string, values ...interface{}) Filter { return listFilter(field, IN, values) }
+
+// Nin - field equals none of values
+func Nin(field string, values ...interface{}) Filter { return listFilter(field, NIN, values) }
+
+// Exists - field is present, or absent when exists is false
+func Exists(field string, exists bool) Filter { return fieldFilter(field, EXISTS, exists) }
+
+// Regex - string field matches regular expression pattern
+func Regex(field, pattern string) Filter {
+	_, err := regexp.Compile(pattern)
+	if err != nil {
+		return Filter{err: err}
+	}
+
+	return fieldFilter(field, REGEX, pattern)
+}
+
+// ElemMatch - array field has an element matching filter
+//
+// Fields of filter are relative to the array element.  Filters on the empty
+// field name apply to the element itself, for example ElemMatch("Scores", Gt("", 80)).
+func ElemMatch(field string, filter Filter) Filter {
+	if filter.err != nil {
+		return filter
+	}
+
+	if filter.expr != nil && filter.field == "" {
+		return fieldFilter(field, ELEMMATCH, filter.expr)
+	}
+
+	return fieldFilter(field, ELEMMATCH, filter.query)
+}
+
+// Not - field does not match single field filter
+func Not(filter Filter) Filter {
+	if filter.err != nil {
+		return filter
+	}
+
+	if filter.expr == nil {
+		return Filter{err: errors.New("Not requires a single field filter")}
+	}
+
+	return fieldFilter(filter.field, NOT, filter.expr)
+}
+
+// And - all filters match
+func And(filters ...Filter) Filter { return logicalFilter(AND, filters) }
+
+// Or - any of filters match
+func Or(filters ...Filter) Filter { return logicalFilter(OR, filters) }
+
+// And - filter and all other filters match
+func (f Filter) And(filters ...Filter) Filter {
+	return And(append([]Filter{f}, filters...)...)
+}
+
+// Or - filter or any of other filters match
+func (f Filter) Or(filters ...Filter) Filter {
+	return Or(append([]Filter{f}, filters...)...)
+}
+
+// Query - query map accepted by Query and other query methods
+func (f Filter) Query() (map[string]interface{}, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return f.query, nil
+}
+
+// Err - error found while building filter
+func (f Filter) Err() error {
+	return f.err
+}
+
+func fieldFilter(field, op string, operand interface{}) Filter {
+	expr := map[string]interface{}{op: operand}
+
+	return Filter{
+		query: map[string]interface{}{field: expr},
+		field: field,
+		expr:  expr,
+	}
+}
+
+func comparisonFilter(field, op string, value interface{}) Filter {
+	value, err := normalizeFilterValue(value)
+	if err != nil {
+		return Filter{err: err}
+	}
+
+	switch value.(type) {
+	case float64, int64, string, time.Time:
+		return fieldFilter(field, op, value)
+	}
+
+	return Filter{err: errors.New("Invalid type for comparison")}
+}
+
+func listFilter(field, op string, values []interface{}) Filter {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		value, err := normalizeFilterValue(value)
+		if err != nil {
+			return Filter{err: err}
+		}
+		list = append(list, value)
+	}
+
+	return fieldFilter(field, op, list)
+}
+
+func logicalFilter(op string, filters []Filter) Filter {
+	if len(filters) == 0 {
+		return Filter{err: errors.New(op + " requires at least one filter")}
+	}
+
+	queryList := make([]interface{}, 0, len(filters))
+	for _, filter := range filters {
+		if filter.err != nil {
+			return filter
+		}
+		queryList = append(queryList, filter.query)
+	}
+
+	return Filter{query: map[string]interface{}{op: queryList}}
+}
+
+// normalizeFilterValue - convert Go values of named and sized types to the
+// int64, float64 and string values stored by json decoding
+func normalizeFilterValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case bool, nil, time.Time, map[string]interface{}, []interface{}:
+		return value, nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return float64(v.Uint()), nil
+		}
+		return int64(v.Uint()), nil
+
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+
+	case reflect.String:
+		return v.String(), nil
+	}
+
+	return nil, errors.New("Unsupported filter value type")
+}
+
+func toInterfaces[V FieldValue](values []V) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
@@ -0,0 +1,168 @@
+package memj
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func insertFilterDocuments(t *testing.T, memj *MemJ) bool {
+	for i := 0; i < 100; i++ {
+		payloadText := fmt.Sprintf(`{"OrderID": "id-%d", "OrderPrice": %d, "Order": {"Status": "status-%d"}, "Scores": [%d, %d]}`, i, i, i%3, i, i+100)
+		if i%10 == 0 {
+			payloadText = fmt.Sprintf(`{"OrderID": "id-%d", "OrderPrice": %d}`, i, i)
+		}
+
+		var payload map[string]interface{}
+		err := json.Unmarshal([]byte(payloadText), &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return false
+		}
+
+		_, err = memj.Insert("TestCollection", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func queryFilter(t *testing.T, memj *MemJ, filter Filter) []map[string]interface{} {
+	query, err := filter.Query()
+
+	if err != nil {
+		t.Error("Error building filter: ", err)
+		return nil
+	}
+
+	documents, err := memj.Query("TestCollection", query, NoLimit)
+
+	if err != nil {
+		t.Error("Error in Query: ", err)
+		return nil
+	}
+
+	return documents
+}
+
+func TestFilterComparison(t *testing.T) {
+	memj, _ := New()
+	if !insertFilterDocuments(t, memj) {
+		return
+	}
+
+	price := Field[int]("OrderPrice")
+	documents := queryFilter(t, memj, price.Gte(50).And(price.Lt(60)))
+
+	if len(documents) != 10 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	documents = queryFilter(t, memj, Or(Eq("OrderID", "id-7"), Field[string]("OrderID").Eq("id-8")))
+
+	if len(documents) != 2 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestFilterIn(t *testing.T) {
+	memj, _ := New()
+	if !insertFilterDocuments(t, memj) {
+		return
+	}
+
+	documents := queryFilter(t, memj, In("OrderPrice", 1, 2, 3, 500))
+
+	if len(documents) != 3 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	documents = queryFilter(t, memj, Field[string]("Order.Status").Nin("status-0", "status-1"))
+
+	if len(documents) != 40 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestFilterExistsAndNot(t *testing.T) {
+	memj, _ := New()
+	if !insertFilterDocuments(t, memj) {
+		return
+	}
+
+	documents := queryFilter(t, memj, Exists("Order.Status", false))
+
+	if len(documents) != 10 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	documents = queryFilter(t, memj, Not(Gte("OrderPrice", 10)))
+
+	if len(documents) != 10 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestFilterRegexAndElemMatch(t *testing.T) {
+	memj, _ := New()
+	if !insertFilterDocuments(t, memj) {
+		return
+	}
+
+	documents := queryFilter(t, memj, Regex("OrderID", "^id-9[0-9]$"))
+
+	if len(documents) != 10 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	documents = queryFilter(t, memj, ElemMatch("Scores", Gt("", 195)))
+
+	if len(documents) != 4 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestFilterValidation(t *testing.T) {
+	invalid := []Filter{
+		Gt("OrderPrice", []interface{}{1}),
+		Regex("OrderID", "(unclosed"),
+		Not(And(Eq("OrderID", "id-1"))),
+		Or(),
+		And(Eq("OrderID", "id-1"), Lt("OrderPrice", struct{}{})),
+	}
+
+	for i, filter := range invalid {
+		_, err := filter.Query()
+		if err == nil {
+			t.Error("Invalid filter but no error: ", i)
+			return
+		}
+	}
+}
+
+func TestFilterProducesQueryMap(t *testing.T) {
+	query, err := Or(Eq("Name", "Platypus"), Field[float64]("Order.Price").Gt(10)).Query()
+
+	if err != nil {
+		t.Error("Error building filter: ", err)
+		return
+	}
+
+	encoded, _ := json.Marshal(query)
+	if string(encoded) != `{"$or":[{"Name":"Platypus"},{"Order.Price":{"$gt":10}}]}` {
+		t.Error("Unexpected query: ", string(encoded))
+		return
+	}
+}
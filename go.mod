@@ -0,0 +1,10 @@
+module github.com/robjsliwa/memj
+
+go 1.23.0
+
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/term v0.32.0
+)
+
+require golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,122 @@
+package memj
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator - generates objectid of inserted documents without one
+//
+// NewID must be safe for concurrent use.  Inserts to different collections
+// call it concurrently, and so do inserts to different shards of the same
+// collection, see WithShards.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+type uuidV4Generator struct{}
+
+// NewUUIDv4Generator - random UUID version 4 ids, the default
+func NewUUIDv4Generator() IDGenerator {
+	return uuidV4Generator{}
+}
+
+func (uuidV4Generator) NewID() (string, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+type uuidV7Generator struct{}
+
+// NewUUIDv7Generator - time ordered UUID version 7 ids
+func NewUUIDv7Generator() IDGenerator {
+	return uuidV7Generator{}
+}
+
+func (uuidV7Generator) NewID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+type objectIDGenerator struct {
+	process [5]byte
+	counter atomic.Uint32
+}
+
+// NewObjectIDGenerator - MongoDB style ids, 12 bytes in hex made of seconds
+// since epoch, random value of generator and counter starting at random value
+func NewObjectIDGenerator() IDGenerator {
+	// random bytes of version 4 UUID, uuid.New panics when there is no
+	// source of randomness
+	seed := uuid.New()
+
+	g := &objectIDGenerator{}
+	copy(g.process[:], seed[:5])
+	g.counter.Store(uint32(seed[9])<<16 | uint32(seed[10])<<8 | uint32(seed[11]))
+
+	return g
+}
+
+func (g *objectIDGenerator) NewID() (string, error) {
+	var id [12]byte
+	binary.BigEndian.PutUint32(id[0:4], uint32(time.Now().Unix()))
+	copy(id[4:9], g.process[:])
+	counter := g.counter.Add(1)
+	id[9] = byte(counter >> 16)
+	id[10] = byte(counter >> 8)
+	id[11] = byte(counter)
+
+	return hex.EncodeToString(id[:]), nil
+}
+
+type sequenceGenerator struct {
+	next atomic.Int64
+}
+
+// NewSequenceGenerator - decimal integer ids counting up from start
+func NewSequenceGenerator(start int64) IDGenerator {
+	g := &sequenceGenerator{}
+	g.next.Store(start)
+	return g
+}
+
+func (g *sequenceGenerator) NewID() (string, error) {
+	return strconv.FormatInt(g.next.Add(1)-1, 10), nil
+}
+
+type seededGenerator struct {
+	lock   sync.Mutex
+	random *rand.Rand
+}
+
+// NewSeededGenerator - UUID version 4 shaped ids from pseudo random sequence,
+// generators with the same seed produce the same ids in the same order
+func NewSeededGenerator(seed int64) IDGenerator {
+	return &seededGenerator{random: rand.New(rand.NewSource(seed))}
+}
+
+func (g *seededGenerator) NewID() (string, error) {
+	g.lock.Lock()
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[0:8], g.random.Uint64())
+	binary.BigEndian.PutUint64(id[8:16], g.random.Uint64())
+	g.lock.Unlock()
+
+	id[6] = id[6]&0x0f | 0x40 // version 4
+	id[8] = id[8]&0x3f | 0x80 // variant 10
+
+	return id.String(), nil
+}
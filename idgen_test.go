@@ -0,0 +1,111 @@
+package memj
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestIDGenerators(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([47])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name      string
+		generator IDGenerator
+		pattern   *regexp.Regexp
+	}{
+		{"UUIDv4", NewUUIDv4Generator(), uuidPattern},
+		{"UUIDv7", NewUUIDv7Generator(), uuidPattern},
+		{"ObjectID", NewObjectIDGenerator(), regexp.MustCompile(`^[0-9a-f]{24}$`)},
+		{"Sequence", NewSequenceGenerator(1), regexp.MustCompile(`^[0-9]+$`)},
+		{"Seeded", NewSeededGenerator(42), uuidPattern},
+	}
+
+	for _, test := range tests {
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id, err := test.generator.NewID()
+			if err != nil {
+				t.Error(test.name, ": error generating id: ", err)
+				return
+			}
+			if !test.pattern.MatchString(id) {
+				t.Error(test.name, ": invalid id ", id)
+				return
+			}
+			if seen[id] {
+				t.Error(test.name, ": duplicate id ", id)
+				return
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestUUIDv7GeneratorOrdered(t *testing.T) {
+	generator := NewUUIDv7Generator()
+	previous, _ := generator.NewID()
+	for i := 0; i < 1000; i++ {
+		id, _ := generator.NewID()
+		if id <= previous {
+			t.Error("Ids not ordered: ", previous, id)
+			return
+		}
+		previous = id
+	}
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	memj, _ := New()
+	if err := memj.SetIDGenerator(NewSequenceGenerator(1)); err != nil {
+		t.Error("Error setting ID generator: ", err)
+		return
+	}
+
+	for _, expected := range []string{"1", "2", "3"} {
+		objectID, _ := memj.Insert("Orders", map[string]interface{}{"Name": expected})
+		if objectID != expected {
+			t.Error("Expected objectid ", expected, ", got ", objectID)
+			return
+		}
+	}
+
+	// ids used by callers are skipped
+	memj.Insert("Orders", map[string]interface{}{"objectid": "4"})
+	memj.Insert("Orders", map[string]interface{}{"objectid": "5"})
+	objectID, err := memj.Insert("Orders", map[string]interface{}{"Name": "next"})
+	if err != nil || objectID != "6" {
+		t.Error("Used id not skipped: ", objectID, err)
+		return
+	}
+}
+
+func TestSeededGenerator(t *testing.T) {
+	first, _ := New()
+	first.SetIDGenerator(NewSeededGenerator(7))
+	second, _ := New()
+	second.SetIDGenerator(NewSeededGenerator(7))
+
+	for i := 0; i < 10; i++ {
+		id1, _ := first.Insert("Orders", map[string]interface{}{"Index": i})
+		id2, _ := second.Insert("Orders", map[string]interface{}{"Index": i})
+		if id1 != id2 {
+			t.Error("Seeded generators produced different ids: ", id1, id2)
+			return
+		}
+	}
+
+	other := NewSeededGenerator(8)
+	id, _ := other.NewID()
+	if _, err := first.Find("Orders", id); err != ErrNotFound {
+		t.Error("Different seeds produced the same id")
+		return
+	}
+}
+
+func TestSetNilIDGenerator(t *testing.T) {
+	memj, _ := New()
+	if err := memj.SetIDGenerator(nil); err == nil {
+		t.Error("Expected error for nil generator")
+		return
+	}
+}
@@ -0,0 +1,423 @@
+package memj
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Format - data format of Import and Export
+type Format string
+
+// Import and export format constants
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+	FormatCSV    Format = "csv"
+)
+
+// Import - insert documents read from r in format to collection, returns
+// number of inserted documents
+//
+// Documents are decoded and inserted one at a time so input is never held in
+// memory as a whole.  FormatNDJSON reads a stream of json documents,
+// FormatJSON a json array of documents, both accept extended json.  FormatCSV
+// maps columns by header, dotted header names like address.city create nested
+// documents and values are inferred as json numbers, true, false, null,
+// RFC3339 dates, json arrays, objects and strings in double quotes or other
+// strings.  Empty cells are left out of the document.  Documents inserted
+// before an error are kept.
+func (m *MemJ) Import(collection string, r io.Reader, format Format) (int, error) {
+	return m.ImportCtx(context.Background(), collection, r, format)
+}
+
+// ImportCtx - like Import but takes context
+func (m *MemJ) ImportCtx(ctx context.Context, collection string, r io.Reader, format Format) (int, error) {
+	insert := func(document map[string]interface{}) error {
+		_, err := m.InsertCtx(ctx, collection, document)
+		return err
+	}
+
+	switch format {
+	case FormatNDJSON, FormatJSON:
+		return m.importJSON(r, format == FormatJSON, insert)
+
+	case FormatCSV:
+		return m.importCSV(r, insert)
+	}
+
+	return 0, fmt.Errorf("Unsupported format %q", format)
+}
+
+func (m *MemJ) importJSON(r io.Reader, isArray bool, insert func(map[string]interface{}) error) (int, error) {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	if isArray {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if delim, ok := token.(json.Delim); err == nil && (!ok || delim != '[') {
+			err = errors.New("Expected JSON array of documents")
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	for decoder.More() {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err != nil {
+			return count, fmt.Errorf("document %d: %v", count+1, err)
+		}
+
+		document, err := ParseJSON(raw)
+		if err == nil {
+			err = insert(document)
+		}
+		if err != nil {
+			return count, fmt.Errorf("document %d: %v", count+1, err)
+		}
+		count++
+	}
+
+	if isArray {
+		_, err := decoder.Token()
+		if err != nil {
+			return count, err
+		}
+	}
+
+	return count, nil
+}
+
+func (m *MemJ) importCSV(r io.Reader, insert func(map[string]interface{}) error) (int, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns, err := m.csvColumns(header)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		document := make(map[string]interface{})
+		for i, cell := range record {
+			if cell == "" {
+				continue
+			}
+
+			level := document
+			for _, key := range columns[i][:len(columns[i])-1] {
+				nested, ok := level[key].(map[string]interface{})
+				if !ok {
+					nested = make(map[string]interface{})
+					level[key] = nested
+				}
+				level = nested
+			}
+			level[columns[i][len(columns[i])-1]] = m.inferCSVValue(cell)
+		}
+
+		err = insert(document)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return count, fmt.Errorf("line %d: %v", line, err)
+		}
+		count++
+	}
+}
+
+// csvColumns - split header names to paths, a column cannot be both a value
+// and a parent of another column
+func (m *MemJ) csvColumns(header []string) ([][]string, error) {
+	seen := make(map[string]bool, len(header))
+	for _, name := range header {
+		if seen[name] {
+			return nil, fmt.Errorf("Duplicate CSV column %q", name)
+		}
+		seen[name] = true
+	}
+
+	columns := make([][]string, len(header))
+	for i, name := range header {
+		keys := strings.Split(name, ".")
+		for _, key := range keys {
+			if key == "" {
+				return nil, fmt.Errorf("Invalid CSV column %q", name)
+			}
+		}
+		for j := 1; j < len(keys); j++ {
+			parent := strings.Join(keys[:j], ".")
+			if seen[parent] {
+				return nil, fmt.Errorf("CSV column %q conflicts with column %q", name, parent)
+			}
+		}
+		columns[i] = keys
+	}
+
+	return columns, nil
+}
+
+// inferCSVValue - convert cell text to value of the type it looks like
+func (m *MemJ) inferCSVValue(cell string) interface{} {
+	switch cell {
+	case "true":
+		return true
+
+	case "false":
+		return false
+
+	case "null":
+		return nil
+	}
+
+	switch cell[0] {
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		if json.Valid([]byte(cell)) {
+			value, err := convertJSONNumber(json.Number(cell))
+			if err == nil {
+				return value
+			}
+		}
+		if t, err := time.Parse(time.RFC3339Nano, cell); err == nil {
+			return t
+		}
+
+	case '[', '{':
+		if json.Valid([]byte(cell)) {
+			wrapped, err := ParseJSON(`{"value":` + cell + `}`)
+			if err == nil {
+				return wrapped["value"]
+			}
+		}
+
+	case '"':
+		var str string
+		if json.Unmarshal([]byte(cell), &str) == nil {
+			return str
+		}
+	}
+
+	return cell
+}
+
+// Export - write documents of collection matching query to w in format,
+// nil query exports all documents, returns number of written documents
+//
+// FormatNDJSON writes one extended json document per line and FormatJSON a
+// json array of them.  FormatCSV writes header row with dotted paths of all
+// nested fields, primary key first and others sorted, arrays are written as
+// json text.  Strings that Import would read as other values, such as "42",
+// empty strings and strings starting with double quote are written as json
+// strings, so CSV export is imported back unchanged.  Documents are written as
+// of the time export starts without blocking writers.
+func (m *MemJ) Export(collection string, w io.Writer, format Format, query map[string]interface{}) (int, error) {
+	return m.ExportCtx(context.Background(), collection, w, format, query)
+}
+
+// ExportCtx - like Export but takes context
+func (m *MemJ) ExportCtx(ctx context.Context, collection string, w io.Writer, format Format, query map[string]interface{}) (int, error) {
+	if format != FormatNDJSON && format != FormatJSON && format != FormatCSV {
+		return 0, fmt.Errorf("Unsupported format %q", format)
+	}
+
+	prepared, err := m.compileFilter(query)
+	if err != nil {
+		return 0, err
+	}
+
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return 0, err
+	}
+
+	each := func(fn func(map[string]interface{}) error) error {
+		for index, document := range c.all() {
+			if err := checkContext(ctx, index); err != nil {
+				return err
+			}
+
+			if prepared != nil {
+				isFound, err := prepared.Match(document)
+				if err != nil {
+					return err
+				}
+				if !isFound {
+					continue
+				}
+			}
+
+			err := fn(document)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if format == FormatCSV {
+		return m.exportCSV(w, each)
+	}
+
+	buf := bufio.NewWriter(w)
+	if format == FormatJSON {
+		buf.WriteString("[")
+	}
+
+	count := 0
+	err = each(func(document map[string]interface{}) error {
+		encoded, err := MarshalExtendedJSON(document)
+		if err != nil {
+			return err
+		}
+
+		if format == FormatJSON && count > 0 {
+			buf.WriteString(",")
+		}
+		if format == FormatJSON {
+			buf.WriteString("\n")
+		}
+		buf.Write(encoded)
+		if format == FormatNDJSON {
+			buf.WriteString("\n")
+		}
+		count++
+
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if format == FormatJSON {
+		buf.WriteString("\n]\n")
+	}
+
+	return count, buf.Flush()
+}
+
+func (m *MemJ) exportCSV(w io.Writer, each func(func(map[string]interface{}) error) error) (int, error) {
+	paths := make(map[string]bool)
+	err := each(func(document map[string]interface{}) error {
+		m.flattenDocument("", document, func(path string, _ interface{}) {
+			paths[path] = true
+		})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]string, 0, len(paths))
+	for path := range paths {
+		header = append(header, path)
+	}
+	sort.Slice(header, func(i, j int) bool {
+		if header[i] == m.primaryKey || header[j] == m.primaryKey {
+			return header[i] == m.primaryKey
+		}
+		return header[i] < header[j]
+	})
+
+	index := make(map[string]int, len(header))
+	for i, path := range header {
+		index[path] = i
+	}
+
+	writer := csv.NewWriter(w)
+	if len(header) > 0 {
+		err = writer.Write(header)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	count := 0
+	record := make([]string, len(header))
+	err = each(func(document map[string]interface{}) error {
+		for i := range record {
+			record[i] = ""
+		}
+
+		var err error
+		m.flattenDocument("", document, func(path string, value interface{}) {
+			var cell string
+			cell, err = m.formatCSVValue(value)
+			record[index[path]] = cell
+		})
+		if err != nil {
+			return err
+		}
+
+		count++
+		return writer.Write(record)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	writer.Flush()
+	return count, writer.Error()
+}
+
+// flattenDocument - call fn with dotted path and value of every field that is
+// not a non empty document
+func (m *MemJ) flattenDocument(prefix string, document map[string]interface{}, fn func(string, interface{})) {
+	for key, value := range document {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			m.flattenDocument(prefix+key+".", nested, fn)
+			continue
+		}
+		fn(prefix+key, value)
+	}
+}
+
+// formatCSVValue - cell text that inferCSVValue converts back to value
+func (m *MemJ) formatCSVValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		if value != "" && value[0] != '"' {
+			if inferred, ok := m.inferCSVValue(value).(string); ok && inferred == value {
+				return value, nil
+			}
+		}
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano), nil
+
+	case nil:
+		return "null", nil
+	}
+
+	encoded, err := MarshalExtendedJSON(value)
+	return string(encoded), err
+}
@@ -0,0 +1,220 @@
+package memj
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportJSON(t *testing.T) {
+	memj, _ := New()
+
+	count, err := memj.Import("Orders", strings.NewReader(`{"OrderID": "id-1", "Price": 10}
+{"OrderID": "id-2", "Price": 20.5, "Created": {"$date": "2024-05-01T00:00:00Z"}}
+`), FormatNDJSON)
+	if err != nil || count != 2 {
+		t.Error("Error importing NDJSON: ", count, err)
+		return
+	}
+
+	count, err = memj.Import("Orders", strings.NewReader(`[{"OrderID": "id-3"}, {"OrderID": "id-4"}]`), FormatJSON)
+	if err != nil || count != 2 {
+		t.Error("Error importing JSON array: ", count, err)
+		return
+	}
+
+	documents, _ := memj.QueryJSON("Orders", `{"OrderID": "id-2"}`, NoLimit)
+	if len(documents) != 1 {
+		t.Error("Imported document not found")
+		return
+	}
+	if _, ok := documents[0]["Created"].(time.Time); !ok {
+		t.Error("Extended JSON date not converted")
+		return
+	}
+
+	count, err = memj.Import("Orders", strings.NewReader(`{"OrderID": "id-5"} 42 {"OrderID": "id-6"}`), FormatNDJSON)
+	if err == nil || count != 1 {
+		t.Error("Expected error after first document: ", count, err)
+		return
+	}
+
+	_, err = memj.Import("Orders", strings.NewReader(`{"OrderID": "id-7"}`), FormatJSON)
+	if err == nil {
+		t.Error("Expected error importing object as JSON array")
+		return
+	}
+
+	_, err = memj.Import("Orders", strings.NewReader(``), "xml")
+	if err == nil {
+		t.Error("Expected error for unsupported format")
+		return
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	memj, _ := New()
+
+	input := `name,age,active,address.city,address.zip,score,joined,tags
+Ann,25,true,Oslo,0150,1.5,2024-05-01T00:00:00Z,"[""a"",""b""]"
+Bob,,false,,,-3,not a date,
+"Smith, Cid",45,null,Bergen,5003,1e3,,
+`
+	count, err := memj.Import("Users", strings.NewReader(input), FormatCSV)
+	if err != nil || count != 3 {
+		t.Error("Error importing CSV: ", count, err)
+		return
+	}
+
+	documents, _ := memj.QueryJSON("Users", `{"name": "Ann"}`, NoLimit)
+	if len(documents) != 1 {
+		t.Error("Imported document not found")
+		return
+	}
+	ann := documents[0]
+
+	if age, ok := ann["age"].(int64); !ok || age != 25 {
+		t.Errorf("Integer not inferred: %#v", ann["age"])
+		return
+	}
+
+	if active, ok := ann["active"].(bool); !ok || !active {
+		t.Errorf("Boolean not inferred: %#v", ann["active"])
+		return
+	}
+
+	address, ok := ann["address"].(map[string]interface{})
+	if !ok || address["city"] != "Oslo" || address["zip"] != "0150" {
+		t.Errorf("Dotted columns not nested or zip not kept as string: %#v", ann["address"])
+		return
+	}
+
+	if score, ok := ann["score"].(float64); !ok || score != 1.5 {
+		t.Errorf("Float not inferred: %#v", ann["score"])
+		return
+	}
+
+	if _, ok := ann["joined"].(time.Time); !ok {
+		t.Errorf("Date not inferred: %#v", ann["joined"])
+		return
+	}
+
+	if tags, ok := ann["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("Array not inferred: %#v", ann["tags"])
+		return
+	}
+
+	documents, _ = memj.QueryJSON("Users", `{"name": "Bob"}`, NoLimit)
+	if _, exists := documents[0]["age"]; exists {
+		t.Error("Empty cell imported")
+		return
+	}
+	if documents[0]["joined"] != "not a date" {
+		t.Error("Text not kept as string")
+		return
+	}
+
+	count, _ = memj.Count("Users", map[string]interface{}{"address.city": "Bergen"})
+	if count != 1 {
+		t.Error("Quoted cell with comma not imported")
+		return
+	}
+
+	_, err = memj.Import("Users", strings.NewReader("a,a.b\n1,2\n"), FormatCSV)
+	if err == nil {
+		t.Error("Expected error for conflicting columns")
+		return
+	}
+}
+
+func TestExport(t *testing.T) {
+	memj, _ := New()
+
+	memj.InsertJSON("Orders", `{"OrderID": "id-1", "Price": 10.0, "Customer": {"Name": "Ann"}, "Items": [1, 2]}`)
+	memj.InsertJSON("Orders", `{"OrderID": "id-2", "Price": 25, "Created": {"$date": "2024-05-01T00:00:00Z"}}`)
+
+	var buf bytes.Buffer
+	count, err := memj.Export("Orders", &buf, FormatNDJSON, nil)
+	if err != nil || count != 2 || strings.Count(buf.String(), "\n") != 2 {
+		t.Error("Error exporting NDJSON: ", count, err, buf.String())
+		return
+	}
+
+	restored, _ := New()
+	count, err = restored.Import("Orders", &buf, FormatNDJSON)
+	if err != nil || count != 2 {
+		t.Error("Error importing exported NDJSON: ", err)
+		return
+	}
+
+	buf.Reset()
+	count, err = memj.Export("Orders", &buf, FormatJSON, map[string]interface{}{"Price": map[string]interface{}{"$gt": 20}})
+	if err != nil || count != 1 || !strings.HasPrefix(buf.String(), "[") {
+		t.Error("Error exporting JSON array: ", count, err, buf.String())
+		return
+	}
+
+	buf.Reset()
+	count, err = memj.Export("Orders", &buf, FormatCSV, nil)
+	if err != nil || count != 2 {
+		t.Error("Error exporting CSV: ", err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "objectid,Created,Customer.Name,Items,OrderID,Price" {
+		t.Error("Incorrect CSV header: ", lines[0])
+		return
+	}
+	if !strings.HasSuffix(lines[1], `,,Ann,"[1,2]",id-1,10.0`) {
+		t.Error("Incorrect CSV row: ", lines[1])
+		return
+	}
+
+	restored, _ = New()
+	_, err = restored.Import("Orders", &buf, FormatCSV)
+	if err != nil {
+		t.Error("Error importing exported CSV: ", err)
+		return
+	}
+
+	documents, _ := restored.QueryJSON("Orders", `{"Customer.Name": "Ann", "Price": 10}`, NoLimit)
+	if len(documents) != 1 {
+		t.Error("CSV export did not round trip")
+		return
+	}
+	if _, ok := documents[0]["Price"].(float64); !ok {
+		t.Errorf("Float type not preserved in CSV: %#v", documents[0]["Price"])
+		return
+	}
+}
+
+func TestExportCSVStrings(t *testing.T) {
+	memj, _ := New()
+	values := []string{"42", "true", "null", "[1]", "2024-05-01T00:00:00Z", `"quoted"`, "", "plain"}
+	for i, value := range values {
+		memj.Insert("Strings", map[string]interface{}{"objectid": strconv.Itoa(i), "Value": value})
+	}
+
+	var buf bytes.Buffer
+	if _, err := memj.Export("Strings", &buf, FormatCSV, nil); err != nil {
+		t.Error("Error exporting CSV: ", err)
+		return
+	}
+
+	restored, _ := New()
+	if _, err := restored.Import("Strings", &buf, FormatCSV); err != nil {
+		t.Error("Error importing exported CSV: ", err)
+		return
+	}
+
+	for i, value := range values {
+		document, err := restored.Find("Strings", strconv.Itoa(i))
+		if err != nil || document["Value"] != value {
+			t.Errorf("Expected string %q to round trip, got %#v %v", value, document["Value"], err)
+			return
+		}
+	}
+}
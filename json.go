@@ -0,0 +1,255 @@
+package memj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Extended json type constants
+const (
+	DateType   = "$date"
+	OIDType    = "$oid"
+	LongType   = "$numberLong"
+	IntType    = "$numberInt"
+	DoubleType = "$numberDouble"
+)
+
+// ParseJSON - decode json object given as string or []byte
+//
+// Integer numbers are decoded as int64 so they keep their precision and other
+// numbers as float64.  Objects using extended json notation are converted:
+// {"$date": "2006-01-02T15:04:05Z"} or {"$date": <milliseconds>} to time.Time,
+// {"$oid": "..."} to string, {"$numberLong": "..."} and {"$numberInt": "..."}
+// to int64 and {"$numberDouble": "..."} to float64.
+func ParseJSON(text interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch text := text.(type) {
+	case string:
+		data = []byte(text)
+
+	case []byte:
+		data = text
+
+	case json.RawMessage:
+		data = text
+
+	default:
+		return nil, errors.New("JSON must be a string or []byte")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	err := decoder.Decode(&document)
+	if err != nil {
+		return nil, err
+	}
+
+	if decoder.More() {
+		return nil, errors.New("Unexpected data after JSON object")
+	}
+
+	value, err := convertJSONValue(document)
+	if err != nil {
+		return nil, err
+	}
+
+	document, _ = value.(map[string]interface{})
+	return document, nil
+}
+
+// QueryJSON - query for objects in collection with query given as json text
+func (m *MemJ) QueryJSON(collection string, query interface{}, limit int) ([]map[string]interface{}, error) {
+	return m.QueryJSONCtx(context.Background(), collection, query, limit)
+}
+
+// QueryJSONCtx - like QueryJSON but takes context
+func (m *MemJ) QueryJSONCtx(ctx context.Context, collection string, query interface{}, limit int) ([]map[string]interface{}, error) {
+	queryPayload, err := ParseJSON(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.QueryCtx(ctx, collection, queryPayload, limit)
+}
+
+// InsertJSON - insert json text to collection
+func (m *MemJ) InsertJSON(collection string, payload interface{}) (string, error) {
+	return m.InsertJSONCtx(context.Background(), collection, payload)
+}
+
+// InsertJSONCtx - like InsertJSON but takes context
+func (m *MemJ) InsertJSONCtx(ctx context.Context, collection string, payload interface{}) (string, error) {
+	document, err := ParseJSON(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return m.InsertCtx(ctx, collection, document)
+}
+
+// UpdateJSON - update existing object identified by objectID with fields given as json text
+func (m *MemJ) UpdateJSON(collection, objectID string, payload interface{}) (bool, error) {
+	return m.UpdateJSONCtx(context.Background(), collection, objectID, payload)
+}
+
+// UpdateJSONCtx - like UpdateJSON but takes context
+func (m *MemJ) UpdateJSONCtx(ctx context.Context, collection, objectID string, payload interface{}) (bool, error) {
+	fields, err := ParseJSON(payload)
+	if err != nil {
+		return false, err
+	}
+
+	return m.UpdateCtx(ctx, collection, objectID, fields)
+}
+
+// MarshalExtendedJSON - encode value as json that ParseJSON decodes to the same types
+//
+// time.Time values are written as {"$date": "..."} and float64 values always
+// carry a fraction or exponent so they are not read back as int64.
+func MarshalExtendedJSON(value interface{}) ([]byte, error) {
+	return json.Marshal(extendedJSONValue(value))
+}
+
+func extendedJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case time.Time:
+		return map[string]interface{}{DateType: value.UTC().Format(time.RFC3339Nano)}
+
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return map[string]interface{}{DoubleType: strconv.FormatFloat(value, 'g', -1, 64)}
+		}
+		str := strconv.FormatFloat(value, 'g', -1, 64)
+		if !strings.ContainsAny(str, ".eE") {
+			str += ".0"
+		}
+		return json.Number(str)
+
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = extendedJSONValue(v)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = extendedJSONValue(v)
+		}
+		return result
+
+	case []map[string]interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = extendedJSONValue(v)
+		}
+		return result
+	}
+
+	return value
+}
+
+func convertJSONValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case json.Number:
+		return convertJSONNumber(value)
+
+	case map[string]interface{}:
+		if len(value) == 1 {
+			converted, isExtended, err := convertExtendedJSON(value)
+			if isExtended || err != nil {
+				return converted, err
+			}
+		}
+
+		for k, v := range value {
+			converted, err := convertJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = converted
+		}
+		return value, nil
+
+	case []interface{}:
+		for i, v := range value {
+			converted, err := convertJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = converted
+		}
+		return value, nil
+	}
+
+	return value, nil
+}
+
+func convertJSONNumber(number json.Number) (interface{}, error) {
+	if !strings.ContainsAny(number.String(), ".eE") {
+		if i, err := number.Int64(); err == nil {
+			return i, nil
+		}
+	}
+
+	return number.Float64()
+}
+
+func convertExtendedJSON(value map[string]interface{}) (interface{}, bool, error) {
+	for k, v := range value {
+		switch k {
+		case DateType:
+			switch v := v.(type) {
+			case string:
+				t, err := time.Parse(time.RFC3339Nano, v)
+				return t, true, err
+
+			case json.Number:
+				millis, err := v.Int64()
+				return time.UnixMilli(millis).UTC(), true, err
+
+			case map[string]interface{}:
+				millis, ok := v[LongType].(string)
+				if ok && len(v) == 1 {
+					n, err := strconv.ParseInt(millis, 10, 64)
+					return time.UnixMilli(n).UTC(), true, err
+				}
+			}
+			return nil, true, errors.New("Invalid $date value")
+
+		case OIDType:
+			oid, ok := v.(string)
+			if !ok {
+				return nil, true, errors.New("Invalid $oid value")
+			}
+			return oid, true, nil
+
+		case LongType, IntType:
+			str, ok := v.(string)
+			if !ok {
+				return nil, true, errors.New("Invalid " + k + " value")
+			}
+			n, err := strconv.ParseInt(str, 10, 64)
+			return n, true, err
+
+		case DoubleType:
+			str, ok := v.(string)
+			if !ok {
+				return nil, true, errors.New("Invalid $numberDouble value")
+			}
+			f, err := strconv.ParseFloat(str, 64)
+			return f, true, err
+		}
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,185 @@
+package memj
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueryJSON(t *testing.T) {
+	memj, _ := New()
+
+	for i := 0; i < 100; i++ {
+		_, err := memj.InsertJSON("TestCollection", fmt.Sprintf(`{"OrderID": "id-%d", "OrderPrice": %d}`, i, i))
+
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	documents, err := memj.QueryJSON("TestCollection", `{"OrderPrice": {"$gte": 90}}`, NoLimit)
+
+	if err != nil {
+		t.Error("Error in QueryJSON: ", err)
+		return
+	}
+
+	if len(documents) != 10 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	documents, err = memj.QueryJSON("TestCollection", []byte(`{"OrderPrice": 42.0}`), NoLimit)
+
+	if err != nil {
+		t.Error("Error in QueryJSON: ", err)
+		return
+	}
+
+	if len(documents) != 1 {
+		t.Error("Integer field not equal to float query value")
+		return
+	}
+}
+
+func TestQueryJSONLargeIntegers(t *testing.T) {
+	memj, _ := New()
+
+	_, err := memj.InsertJSON("TestCollection", `{"Serial": 9007199254740993}`)
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	_, err = memj.InsertJSON("TestCollection", `{"Serial": 9007199254740992}`)
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	documents, err := memj.QueryJSON("TestCollection", `{"Serial": 9007199254740993}`, NoLimit)
+
+	if err != nil {
+		t.Error("Error in QueryJSON: ", err)
+		return
+	}
+
+	if len(documents) != 1 || documents[0]["Serial"] != int64(9007199254740993) {
+		t.Error("Integer precision lost")
+		return
+	}
+}
+
+func TestUpdateJSON(t *testing.T) {
+	memj, _ := New()
+
+	objectID, err := memj.InsertJSON("TestCollection", `{"Name": "Platypus", "Order": {"OrderID": 1}}`)
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	isUpdated, err := memj.UpdateJSON("TestCollection", objectID, `{"Order.OrderID": 2}`)
+
+	if err != nil || !isUpdated {
+		t.Error("Error in UpdateJSON: ", err)
+		return
+	}
+
+	document, _ := memj.Find("TestCollection", objectID)
+	order := document["Order"].(map[string]interface{})
+	if order["OrderID"] != int64(2) {
+		t.Error("Field not updated")
+		return
+	}
+}
+
+func TestExtendedJSON(t *testing.T) {
+	memj, _ := New()
+
+	for i := 1; i <= 5; i++ {
+		_, err := memj.InsertJSON("TestCollection", fmt.Sprintf(
+			`{"Ref": {"$oid": "5f1d7f3a%016d"}, "Created": {"$date": "2020-01-0%dT00:00:00Z"}, "Count": {"$numberLong": "%d"}}`, i, i, i))
+
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	documents, err := memj.QueryJSON("TestCollection", `{"Created": {"$gte": {"$date": "2020-01-04T00:00:00Z"}}}`, NoLimit)
+
+	if err != nil {
+		t.Error("Error in QueryJSON: ", err)
+		return
+	}
+
+	if len(documents) != 2 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+
+	created, ok := documents[0]["Created"].(time.Time)
+	if !ok || !created.Equal(time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Error("$date not decoded")
+		return
+	}
+
+	if documents[0]["Ref"] != "5f1d7f3a0000000000000004" || documents[0]["Count"] != int64(4) {
+		t.Error("$oid or $numberLong not decoded")
+		return
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	for _, text := range []interface{}{
+		`{"Name": }`,
+		`{"Created": {"$date": true}}`,
+		`{"Name": "a"} {"Name": "b"}`,
+		42,
+	} {
+		_, err := ParseJSON(text)
+		if err == nil {
+			t.Error("Invalid JSON but no error: ", text)
+			return
+		}
+	}
+}
+
+func TestMarshalExtendedJSON(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	document := map[string]interface{}{
+		"Price":   10.0,
+		"Count":   int64(9007199254740993),
+		"Created": created,
+		"Tags":    []interface{}{1.5, "a"},
+	}
+
+	encoded, err := MarshalExtendedJSON(document)
+	if err != nil {
+		t.Error("Error encoding document: ", err)
+		return
+	}
+
+	decoded, err := ParseJSON(encoded)
+	if err != nil {
+		t.Error("Error decoding document: ", err)
+		return
+	}
+
+	if price, ok := decoded["Price"].(float64); !ok || price != 10.0 {
+		t.Errorf("Float not preserved: %#v", decoded["Price"])
+		return
+	}
+
+	if count, ok := decoded["Count"].(int64); !ok || count != 9007199254740993 {
+		t.Errorf("Integer not preserved: %#v", decoded["Count"])
+		return
+	}
+
+	if date, ok := decoded["Created"].(time.Time); !ok || !date.Equal(created) {
+		t.Errorf("Date not preserved: %#v", decoded["Created"])
+		return
+	}
+}
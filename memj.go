@@ -1,12 +1,16 @@
 package memj
 
 import (
+	"context"
 	"errors"
+	"log"
+	"math"
+	"os"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
-
-	"github.com/google/uuid"
+	"time"
 )
 
 // Limit constants
@@ -27,87 +31,344 @@ const (
 	NIN = "$nin"
 )
 
+// Element and evaluation operator constants
+const (
+	EXISTS    = "$exists"
+	REGEX     = "$regex"
+	NOT       = "$not"
+	ELEMMATCH = "$elemMatch"
+)
+
 // Logical operator constants
 const (
 	AND = "$and"
 	OR  = "$or"
 )
 
+// Errors returned by MemJ
+var (
+	ErrNotFound         = errors.New("Not found")
+	ErrInvalidFieldPath = errors.New("Invalid field path")
+	ErrDuplicateID      = errors.New("Duplicate primary key")
+	ErrPrimaryKeyChange = errors.New("Primary key cannot be changed")
+)
+
+// DefaultPrimaryKey - field holding id of documents unless changed with
+// SetPrimaryKey
+const DefaultPrimaryKey = "objectid"
+
 // MemJ - memory json
 type MemJ struct {
-	mutexLock       sync.RWMutex
-	collectionLocks map[string]*sync.RWMutex
-	data            map[string][]map[string]interface{}
+	// mutexLock - guards collections map, documents are guarded by lock of
+	// their collection
+	mutexLock   sync.RWMutex
+	collections map[string]*collection
+
+	idGenerator     IDGenerator
+	primaryKey      string
+	copyOnRead      bool
+	persistencePath string
+	shards          int
+	parallelQuery   int
+	clock           Clock
+	logger          Logger
+	metrics         Metrics
+	reaperInterval  time.Duration
+
+	// reaperLock - guards reaper goroutine state and closed
+	reaperLock sync.Mutex
+	reaperStop chan struct{}
+	reaperDone chan struct{}
+	closed     bool
+
+	// checkpoints - collections remembered by Checkpoint, guarded by
+	// mutexLock
+	checkpoints map[string]map[string]*collection
 }
 
-// New - create new instance of MemJ
-func New() (*MemJ, error) {
+// New - create new instance of MemJ configured by options
+func New(opts ...Option) (*MemJ, error) {
 	memj := &MemJ{
-		collectionLocks: make(map[string]*sync.RWMutex),
-		data:            make(map[string][]map[string]interface{}),
+		collections: make(map[string]*collection),
+		idGenerator: NewUUIDv4Generator(),
+		primaryKey:  DefaultPrimaryKey,
+		shards:      1,
+		clock:       systemClock{},
+		logger:      log.Default(),
+
+		reaperInterval: DefaultReaperInterval,
+	}
+
+	for _, opt := range opts {
+		err := opt(memj)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if memj.persistencePath != "" {
+		err := memj.LoadFile(memj.persistencePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
 	}
 
 	return memj, nil
 }
 
+// Close - stop reaper goroutine and save all collections to the file given to
+// WithPersistence
+//
+// Documents can still be read and written after Close, but expired documents
+// are only removed by writes.
+func (m *MemJ) Close() error {
+	m.stopReaper()
+
+	if m.persistencePath == "" {
+		return nil
+	}
+
+	return m.SaveFile(m.persistencePath)
+}
+
+// SetIDGenerator - generate objectid of inserted documents with generator,
+// NewUUIDv4Generator by default
+//
+// Set the generator before inserting documents, it must not be changed while
+// other goroutines insert.
+func (m *MemJ) SetIDGenerator(generator IDGenerator) error {
+	if generator == nil {
+		return errors.New("ID generator must not be nil")
+	}
+	m.idGenerator = generator
+	return nil
+}
+
+// SetPrimaryKey - store id of documents in field name instead of
+// DefaultPrimaryKey, for example "_id" or "id"
+//
+// Values of the primary key are strings and cannot be changed by updates.  The
+// primary key is set before any collection is created, documents stored under
+// the previous one would not be found.
+func (m *MemJ) SetPrimaryKey(name string) error {
+	if name == "" || strings.Contains(name, ".") || strings.HasPrefix(name, "$") {
+		return errors.New("Primary key must be a top level field name")
+	}
+
+	m.mutexLock.Lock()
+	defer m.mutexLock.Unlock()
+
+	if len(m.collections) > 0 {
+		return errors.New("Primary key must be set before collections are created")
+	}
+	m.primaryKey = name
+	return nil
+}
+
+// PrimaryKey - name of field holding id of documents
+func (m *MemJ) PrimaryKey() string {
+	return m.primaryKey
+}
+
 // Insert - insert json payload to collection
+//
+// A string primary key in payload is kept, ErrDuplicateID is returned when
+// collection already has document with it.  Otherwise primary key is set to
+// id from the ID generator of MemJ.  Payload is not modified, the stored
+// document is its copy with primary key set.
 func (m *MemJ) Insert(collection string, payload map[string]interface{}) (string, error) {
-	lock := m.getCollectionLock(collection)
+	return m.InsertCtx(context.Background(), collection, payload)
+}
+
+// InsertCtx - like Insert but takes context
+func (m *MemJ) InsertCtx(ctx context.Context, collection string, payload map[string]interface{}) (objectID string, err error) {
+	start := m.now()
+	defer func() { m.observe("Insert", collection, start, boolCount(err == nil), err) }()
+
+	c, unlock, err := m.lockWrite(ctx, collection)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	var s *store
+	var unlockShard func()
+	objectID, provided := payload[m.primaryKey].(string)
+	if provided {
+		s, unlockShard, err = m.lockShard(ctx, c, objectID)
+		if err != nil {
+			return "", err
+		}
+		defer unlockShard()
+
+		if s.ids[objectID] {
+			return "", ErrDuplicateID
+		}
+	} else if _, ok := payload[m.primaryKey]; ok {
+		return "", errors.New("Primary key " + m.primaryKey + " must be a string")
+	} else {
+		objectID, s, unlockShard, err = m.generateID(ctx, c)
+		if err != nil {
+			return "", err
+		}
+		defer unlockShard()
+	}
+
+	document := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		document[k] = v
+	}
+	document[m.primaryKey] = objectID
 
-	lock.Lock()
-	defer lock.Unlock()
+	err = m.validateInsert(c, collection, document)
+	if err != nil {
+		return "", err
+	}
 
-	objectID := uuid.New().String()
-	payload["objectid"] = objectID
-	m.data[collection] = append(m.data[collection], payload)
+	err = m.appendCapped(c, s, document)
+	if err != nil {
+		return "", err
+	}
 
 	return objectID, nil
 }
 
+// generateID - new id not used in collection, returns it with its locked
+// shard and function unlocking it
+//
+// Generated ids may clash with ids given by callers, for example a sequence
+// continues below ids of loaded snapshot.  Clashing ids are skipped, a
+// generator producing distinct ids finds free one within number of documents
+// ever inserted plus one tries.  Other shards are written concurrently, so
+// their documents are not counted.
+func (m *MemJ) generateID(ctx context.Context, c *collection) (string, *store, func(), error) {
+	tries := int(c.sequence.Load()) + 1
+	for i := 0; i < tries; i++ {
+		objectID, err := m.idGenerator.NewID()
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		s, unlock, err := m.lockShard(ctx, c, objectID)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if !s.ids[objectID] {
+			return objectID, s, unlock, nil
+		}
+		unlock()
+	}
+
+	return "", nil, nil, ErrDuplicateID
+}
+
 // Find - find collection with objectId in collection
 func (m *MemJ) Find(collection, objectID string) (map[string]interface{}, error) {
-	lock := m.getCollectionLock(collection)
+	return m.FindCtx(context.Background(), collection, objectID)
+}
 
-	lock.RLock()
-	defer lock.RUnlock()
+// FindCtx - like Find but takes context
+func (m *MemJ) FindCtx(ctx context.Context, collection, objectID string) (document map[string]interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("Find", collection, start, boolCount(document != nil), err) }()
 
-	for _, value := range m.data[collection] {
-		if value["objectid"] == objectID {
-			return value, nil
-		}
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, errors.New("Not found")
+	value, err := m.findVersion(ctx, c, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.readDocument(value), nil
 }
 
 // FindAll - return all documents in the collection
 func (m *MemJ) FindAll(collection string) ([]map[string]interface{}, error) {
-	lock := m.getCollectionLock(collection)
+	return m.FindAllCtx(context.Background(), collection)
+}
+
+// FindAllCtx - like FindAll but takes context
+func (m *MemJ) FindAllCtx(ctx context.Context, collection string) (documents []map[string]interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("FindAll", collection, start, len(documents), err) }()
 
-	lock.RLock()
-	defer lock.RUnlock()
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
 
-	return m.data[collection], nil
+	return m.readDocuments(c.all()), nil
 }
 
 // Update - update existing object identified by objectID
 func (m *MemJ) Update(collection, objectID string, payload map[string]interface{}) (bool, error) {
-	lock := m.getCollectionLock(collection)
+	return m.UpdateCtx(context.Background(), collection, objectID, payload)
+}
+
+// UpdateCtx - like Update but takes context
+func (m *MemJ) UpdateCtx(ctx context.Context, collection, objectID string, payload map[string]interface{}) (isUpdated bool, err error) {
+	start := m.now()
+	defer func() { m.observe("Update", collection, start, boolCount(isUpdated), err) }()
+
+	c, unlock, err := m.lockWrite(ctx, collection)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
 
-	lock.Lock()
-	defer lock.Unlock()
+	s, unlockShard, err := m.lockShard(ctx, c, objectID)
+	if err != nil {
+		return false, err
+	}
+	defer unlockShard()
 
-	for index, value := range m.data[collection] {
-		if value["objectid"] == objectID {
-			return m.updateFields(collection, index, payload)
+	for index, value := range s.documents {
+		if value[m.primaryKey] == objectID {
+			return m.updateFields(c, s, collection, index, payload)
 		}
 	}
 
-	return false, errors.New("Not found")
+	return false, ErrNotFound
 }
 
-func (m *MemJ) updateFields(collection string, index int, payload map[string]interface{}) (bool, error) {
-	document := m.data[collection][index]
+// updateFields - replace document at index of store of collection with its
+// updated copy, documents are never changed in place because versions read
+// them without the lock
+func (m *MemJ) updateFields(c *collection, s *store, name string, index int, payload map[string]interface{}) (bool, error) {
+	document := s.documents[index]
+
+	if newID, ok := payload[m.primaryKey]; ok && newID != document[m.primaryKey] {
+		return false, ErrPrimaryKeyChange
+	}
+
+	updated := m.copyDocument(document)
+	err := m.applyUpdate(updated, payload)
+	if err != nil {
+		return false, err
+	}
+
+	if c.validator != nil {
+		err = m.validateUpdate(c, name, document, updated)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// size of capped collection is kept without recounting documents
+	if c.capped.limitsSize() && m.approximateSize(updated) != m.approximateSize(document) {
+		return false, errors.New("Cannot change size of document in capped collection")
+	}
+
+	s.own()
+	s.documents[index] = updated
+
+	return true, nil
+}
+
+func (m *MemJ) applyUpdate(document, payload map[string]interface{}) error {
 	for k, v := range payload {
 		queryParts := strings.Split(k, ".")
 		queryPartsLen := len(queryParts)
@@ -122,45 +383,157 @@ func (m *MemJ) updateFields(collection string, index int, payload map[string]int
 					var ok bool
 					subDocument, ok = subDocument[key].(map[string]interface{})
 					if !ok {
-						return false, errors.New("Invalid field path")
+						return ErrInvalidFieldPath
 					}
 				}
 			}
 		}
 	}
-	return true, nil
+	return nil
+}
+
+// readDocument - document returned by reads, its deep copy with copy-on-read
+func (m *MemJ) readDocument(document map[string]interface{}) map[string]interface{} {
+	if !m.copyOnRead {
+		return document
+	}
+	return m.copyDocument(document)
+}
+
+// readDocuments - documents returned by reads, deep copies with copy-on-read
+func (m *MemJ) readDocuments(documents []map[string]interface{}) []map[string]interface{} {
+	if !m.copyOnRead || documents == nil {
+		return documents
+	}
+
+	copies := make([]map[string]interface{}, len(documents))
+	for i, document := range documents {
+		copies[i] = m.copyDocument(document)
+	}
+	return copies
+}
+
+// copyDocument - deep copy of document
+func (m *MemJ) copyDocument(document map[string]interface{}) map[string]interface{} {
+	return m.copyValue(document).(map[string]interface{})
+}
+
+func (m *MemJ) copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = m.copyValue(v)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = m.copyValue(v)
+		}
+		return result
+	}
+
+	return value
 }
 
 // Delete - delete object in collection identified by objectID
 func (m *MemJ) Delete(collection, objectID string) (bool, error) {
-	lock := m.getCollectionLock(collection)
+	return m.DeleteCtx(context.Background(), collection, objectID)
+}
+
+// DeleteCtx - like Delete but takes context
+func (m *MemJ) DeleteCtx(ctx context.Context, collection, objectID string) (isDeleted bool, err error) {
+	start := m.now()
+	defer func() { m.observe("Delete", collection, start, boolCount(isDeleted), err) }()
 
-	lock.Lock()
-	defer lock.Unlock()
+	c, unlock, err := m.lockWrite(ctx, collection)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if c.capped != nil {
+		return false, ErrCappedDelete
+	}
+
+	s, unlockShard, err := m.lockShard(ctx, c, objectID)
+	if err != nil {
+		return false, err
+	}
+	defer unlockShard()
 
-	for index, value := range m.data[collection] {
-		if value["objectid"] == objectID {
-			m.data[collection] = append(m.data[collection][:index], m.data[collection][index+1:]...)
+	for index, value := range s.documents {
+		if value[m.primaryKey] == objectID {
+			s.remove(index, objectID)
 			return true, nil
 		}
 	}
 
-	return false, errors.New("Not found")
+	return false, ErrNotFound
+}
+
+// MatchAll - query matching all documents, the empty query matches none
+func MatchAll() map[string]interface{} {
+	return map[string]interface{}{AND: []interface{}{}}
 }
 
 // Query - query for object in collection
 func (m *MemJ) Query(collection string, query map[string]interface{}, limit int) ([]map[string]interface{}, error) {
-	maxLimit := 0
+	return m.QueryCtx(context.Background(), collection, query, limit)
+}
+
+// QueryCtx - like Query but takes context
+func (m *MemJ) QueryCtx(ctx context.Context, collection string, query map[string]interface{}, limit int) (results []map[string]interface{}, err error) {
+	start := m.now()
+	defer func() { m.observe("Query", collection, start, len(results), err) }()
+
+	prepared, err := m.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.queryPrepared(ctx, collection, prepared, limit)
+}
+
+// queryPrepared - documents of collection matching prepared query
+func (m *MemJ) queryPrepared(ctx context.Context, collection string, query *PreparedQuery, limit int) ([]map[string]interface{}, error) {
+	c, err := m.readCollection(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := m.matchVersion(ctx, c, query, limit)
+	return m.readDocuments(results), err
+}
+
+// matchDocuments - documents matching query in order, at most limit of them
+// unless limit is NoLimit
+func (m *MemJ) matchDocuments(ctx context.Context, documents []map[string]interface{}, query map[string]interface{}, limit int) ([]map[string]interface{}, error) {
+	prepared, err := m.Compile(query)
+	if err != nil {
+		return nil, err
+	}
 
-	lock := m.getCollectionLock(collection)
+	return m.matchPrepared(ctx, documents, prepared, limit)
+}
 
-	lock.RLock()
-	defer lock.RUnlock()
+// matchPrepared - documents matching prepared query, see matchDocuments
+func (m *MemJ) matchPrepared(ctx context.Context, documents []map[string]interface{}, query *PreparedQuery, limit int) ([]map[string]interface{}, error) {
+	if m.isParallel(documents) {
+		return m.matchParallel(ctx, documents, query, limit)
+	}
 
+	maxLimit := 0
 	var result []map[string]interface{}
 
-	for _, value := range m.data[collection] {
-		isFound, err := m.performMatchQuery(query, value)
+	for index, value := range documents {
+		if err := checkContext(ctx, index); err != nil {
+			return nil, err
+		}
+
+		isFound, err := query.Match(value)
 		if err != nil {
 			return nil, err
 		}
@@ -179,54 +552,41 @@ func (m *MemJ) Query(collection string, query map[string]interface{}, limit int)
 	return result, nil
 }
 
-func (m *MemJ) performMatchQuery(query, document map[string]interface{}) (bool, error) {
-	var compareValue interface{}
-	var err error
-	isFound := false
-	for k := range query {
-		key := strings.Split(k, ".")
-		if len(key) == 1 {
-			if m.isLogicalOperator(k) {
-				queryList, ok := query[k].([]interface{})
-				if !ok {
-					return false, errors.New("Logical operator query has invalid syntax.  Expected a list of queries.")
-				}
-				isFound, err = m.performLogicalOp(k, queryList, document)
-				break
-			} else {
-				var opType string
-				var compareToValue interface{}
-				var isComparison bool
-				opType, compareToValue, isComparison, err = m.isComparisonOperator(query[k])
-				if err != nil {
-					return false, err
-				}
-				if isComparison {
-					docValue := document[k]
-					isFound, err = m.performComperisonOp(opType, docValue, compareToValue)
-					if err != nil {
-						return false, err
-					}
-					continue
-				} else {
-					compareValue = document[k]
-				}
-			}
-		} else {
-			compareValue = m.getNestedQueryValue(key, document)
-		}
-		if query[k] == compareValue {
-			isFound = true
-		} else {
-			isFound = false
-			break
+func (m *MemJ) listContains(list, value interface{}) bool {
+	values, ok := list.([]interface{})
+	if !ok {
+		return false
+	}
+
+	for _, v := range values {
+		if m.valuesEqual(v, value) {
+			return true
 		}
 	}
+	return false
+}
+
+func (m *MemJ) valuesEqual(value1, value2 interface{}) bool {
+	if value1 == nil || value2 == nil {
+		return value1 == value2
+	}
 
-	return isFound, err
+	if isEqual, ok := m.compareValues(EQ, value1, value2); ok {
+		return isEqual
+	}
+
+	if !reflect.TypeOf(value1).Comparable() || !reflect.TypeOf(value2).Comparable() {
+		return reflect.DeepEqual(value1, value2)
+	}
+
+	return value1 == value2
 }
 
 func (m *MemJ) performComperisonOp(op string, compVal1, compVal2 interface{}) (bool, error) {
+	if isFound, ok := m.compareValues(op, compVal1, compVal2); ok {
+		return isFound, nil
+	}
+
 	if reflect.TypeOf(compVal1) != reflect.TypeOf(compVal2) {
 		return false, errors.New("Cannot compare values of different types")
 	}
@@ -238,17 +598,66 @@ func (m *MemJ) performComperisonOp(op string, compVal1, compVal2 interface{}) (b
 		isFound := m.compareStrings(op, compVal1Str, compVal2Str)
 		return isFound, nil
 
-	case float64:
-		compVal1Float, _ := compVal1.(float64)
-		compVal2Float, _ := compVal2.(float64)
-		isFound := m.compareFloats(op, compVal1Float, compVal2Float)
-		return isFound, nil
+	case bool, nil:
+		switch op {
+		case EQ:
+			return compVal1 == compVal2, nil
+
+		case NE:
+			return compVal1 != compVal2, nil
+		}
 	}
 
 	return false, nil
 }
 
-func (m *MemJ) compareFloats(op string, compVal1, compVal2 float64) bool {
+// compareValues - compare numbers of any numeric type or times, ok is false for other values
+func (m *MemJ) compareValues(op string, compVal1, compVal2 interface{}) (bool, bool) {
+	time1, isTime1 := compVal1.(time.Time)
+	time2, isTime2 := compVal2.(time.Time)
+	if isTime1 && isTime2 {
+		return m.compareInts(op, int64(time1.Compare(time2)), 0), true
+	}
+
+	int1, float1, isInt1, isNumber1 := numberValue(compVal1)
+	int2, float2, isInt2, isNumber2 := numberValue(compVal2)
+	if !isNumber1 || !isNumber2 {
+		return false, false
+	}
+
+	if isInt1 && isInt2 {
+		return m.compareInts(op, int1, int2), true
+	}
+
+	return m.compareFloats(op, float1, float2), true
+}
+
+// numberValue - value of numeric types as int64 and float64, isInt when value
+// has integer type and fits int64
+func numberValue(value interface{}) (int64, float64, bool, bool) {
+	if value == nil {
+		return 0, 0, false, false
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), float64(v.Int()), true, true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, float64(v.Uint()), false, true
+		}
+		return int64(v.Uint()), float64(v.Uint()), true, true
+
+	case reflect.Float32, reflect.Float64:
+		return 0, v.Float(), false, true
+	}
+
+	return 0, 0, false, false
+}
+
+func (m *MemJ) compareInts(op string, compVal1, compVal2 int64) bool {
 	switch op {
 	case GT:
 		return compVal1 > compVal2
@@ -272,7 +681,7 @@ func (m *MemJ) compareFloats(op string, compVal1, compVal2 float64) bool {
 	return false
 }
 
-func (m *MemJ) compareStrings(op, compVal1, compVal2 string) bool {
+func (m *MemJ) compareFloats(op string, compVal1, compVal2 float64) bool {
 	switch op {
 	case GT:
 		return compVal1 > compVal2
@@ -296,32 +705,28 @@ func (m *MemJ) compareStrings(op, compVal1, compVal2 string) bool {
 	return false
 }
 
-func (m *MemJ) performLogicalOp(operator string,
-	queryList []interface{},
-	document map[string]interface{}) (bool, error) {
+func (m *MemJ) compareStrings(op, compVal1, compVal2 string) bool {
+	switch op {
+	case GT:
+		return compVal1 > compVal2
+
+	case GTE:
+		return compVal1 >= compVal2
 
-	var opSuccessList []bool
-	for _, query := range queryList {
-		queryMap, _ := query.(map[string]interface{})
-		isFound, err := m.performMatchQuery(queryMap, document)
-		if err != nil {
-			return false, err
-		}
+	case LT:
+		return compVal1 < compVal2
 
-		opSuccessList = append(opSuccessList, isFound)
-	}
+	case LTE:
+		return compVal1 <= compVal2
 
-	var isSuccess bool
-	switch operator {
-	case OR:
-		isSuccess = m.any(opSuccessList)
-		break
+	case NE:
+		return compVal1 != compVal2
 
-	case AND:
-		isSuccess = m.all(opSuccessList)
+	case EQ:
+		return compVal1 == compVal2
 	}
 
-	return isSuccess, nil
+	return false
 }
 
 func (m *MemJ) isComparisonOperator(op interface{}) (string, interface{}, bool, error) {
@@ -336,111 +741,167 @@ func (m *MemJ) isComparisonOperator(op interface{}) (string, interface{}, bool,
 
 	for k, v := range opType {
 		switch k {
-		case EQ, GT, GTE, LT, LTE, NE:
-			switch v := v.(type) {
-			case float64, string:
+		case EQ, NE:
+			switch v.(type) {
+			case bool, nil:
 				return k, v, true, nil
+			}
+			fallthrough
 
-			default:
+		case GT, GTE, LT, LTE:
+			if !m.isComparableOperand(v) {
 				return "", nil, false, errors.New("Invalid type for comparison")
 			}
+			return k, v, true, nil
+
+		case IN, NIN:
+			if _, ok := v.([]interface{}); !ok {
+				return "", nil, false, errors.New("Invalid type for " + k + ".  Expected a list of values.")
+			}
+			return k, v, true, nil
 
-			// TODO : this is not implemented yet
-			/*case IN, NIN:
-			  return k, v, true, nil*/
+		case EXISTS:
+			if _, ok := v.(bool); !ok {
+				return "", nil, false, errors.New("Invalid type for $exists.  Expected a boolean.")
+			}
+			return k, v, true, nil
+
+		case REGEX:
+			pattern, ok := v.(string)
+			if !ok {
+				return "", nil, false, errors.New("Invalid type for $regex.  Expected a string.")
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return "", nil, false, err
+			}
+			return k, re, true, nil
+
+		case NOT:
+			_, _, isOperator, err := m.isComparisonOperator(v)
+			if err != nil {
+				return "", nil, false, err
+			}
+			if !isOperator {
+				return "", nil, false, errors.New("Invalid type for $not.  Expected an operator expression.")
+			}
+			return k, v, true, nil
+
+		case ELEMMATCH:
+			query, ok := v.(map[string]interface{})
+			if !ok {
+				return "", nil, false, errors.New("Invalid type for $elemMatch.  Expected a query.")
+			}
+			_, _, _, err := m.isComparisonOperator(query)
+			if err != nil {
+				return "", nil, false, err
+			}
+			return k, v, true, nil
 		}
 	}
 
 	return "", nil, false, nil
 }
 
-func (m *MemJ) isLogicalOperator(key string) bool {
-	if key == OR || key == AND {
+func (m *MemJ) isComparableOperand(value interface{}) bool {
+	switch value.(type) {
+	case string, time.Time:
 		return true
 	}
-	return false
-}
 
-func (m *MemJ) all(logicList []bool) bool {
-	for _, b := range logicList {
-		if !b {
-			return false
-		}
-	}
-	return true
+	_, _, _, isNumber := numberValue(value)
+	return isNumber
 }
 
-func (m *MemJ) any(logicList []bool) bool {
-	for _, b := range logicList {
-		if b {
-			return true
-		}
+func (m *MemJ) isLogicalOperator(key string) bool {
+	if key == OR || key == AND {
+		return true
 	}
 	return false
 }
 
 func (m *MemJ) getNestedQueryValue(nestedKeys []string, document map[string]interface{}) interface{} {
+	value, _ := m.lookupNestedValue(nestedKeys, document)
+	return value
+}
+
+// lookupNestedValue - value at key path and whether the field exists
+func (m *MemJ) lookupNestedValue(nestedKeys []string, document map[string]interface{}) (interface{}, bool) {
 	var currentValue interface{}
 	var documentLevel interface{} = document
 	for _, key := range nestedKeys {
 		currentDocument, ok := documentLevel.(map[string]interface{})
 		if !ok {
-			return nil
+			return nil, false
 		}
 
-		currentValue = currentDocument[key]
-		documentLevel = currentDocument[key]
+		currentValue, ok = currentDocument[key]
+		if !ok {
+			return nil, false
+		}
+		documentLevel = currentValue
 	}
 
-	return currentValue
+	return currentValue, true
 }
 
-func (m *MemJ) getCollectionLock(collection string) *sync.RWMutex {
-	m.mutexLock.RLock()
+// QueryAndUpdate - query and update documents selected by specified criteria
+func (m *MemJ) QueryAndUpdate(collection string, query, payload map[string]interface{}, limit int) ([]map[string]interface{}, bool, error) {
+	return m.QueryAndUpdateCtx(context.Background(), collection, query, payload, limit)
+}
 
-	cl, ok := m.collectionLocks[collection]
-	m.mutexLock.RUnlock()
+// QueryAndUpdateCtx - like QueryAndUpdate but takes context
+func (m *MemJ) QueryAndUpdateCtx(ctx context.Context, collection string, query, payload map[string]interface{}, limit int) (results []map[string]interface{}, isUpdated bool, err error) {
+	start := m.now()
+	defer func() { m.observe("QueryAndUpdate", collection, start, len(results), err) }()
 
-	if !ok {
-		cl = &sync.RWMutex{}
-		m.mutexLock.Lock()
-		m.collectionLocks[collection] = cl
-		m.mutexLock.Unlock()
+	prepared, err := m.Compile(query)
+	if err != nil {
+		return nil, false, err
 	}
 
-	return cl
+	return m.queryAndUpdatePrepared(ctx, collection, prepared, payload, limit)
 }
 
-// QueryAndUpdate - query and update documents selected by specified criteria
-func (m *MemJ) QueryAndUpdate(collection string, query, payload map[string]interface{}, limit int) ([]map[string]interface{}, bool, error) {
+// queryAndUpdatePrepared - update documents of collection matching prepared
+// query
+func (m *MemJ) queryAndUpdatePrepared(ctx context.Context, collection string, query *PreparedQuery, payload map[string]interface{}, limit int) (results []map[string]interface{}, isUpdated bool, err error) {
 	maxLimit := 0
-	var results []map[string]interface{}
-	var isUpdated bool
-	var err error
 
-	lock := m.getCollectionLock(collection)
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlock()
 
-	lock.RLock()
-	defer lock.RUnlock()
+	scanned := 0
+	c.each(func(s *store, index int) bool {
+		err = checkContext(ctx, scanned)
+		scanned++
+		if err != nil {
+			isUpdated = len(results) > 0
+			return false
+		}
 
-	for index, value := range m.data[collection] {
-		isFound, _ := m.performMatchQuery(query, value)
+		isFound, _ := query.Match(s.documents[index])
 
 		if isFound {
-			isUpdated, err = m.updateFields(collection, index, payload)
+			isUpdated, err = m.updateFields(c, s, collection, index, payload)
 			if err != nil {
 				// TODO: Fix partial update issue
-				return results, false, err
+				isUpdated = false
+				return false
 			}
-			results = append(results, value)
+			results = append(results, m.readDocument(s.documents[index]))
 			if limit != 0 {
 				maxLimit++
 				if maxLimit >= limit {
-					return results, true, nil
+					return false
 				}
 			}
 		}
-	}
+		return true
+	})
 
-	return results, isUpdated, nil
+	return results, isUpdated, err
 }
@@ -30,6 +30,126 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+func TestInsertWithObjectID(t *testing.T) {
+	memj, _ := New()
+
+	payload := map[string]interface{}{"objectid": "order-1", "Name": "Platypus"}
+	objectID, err := memj.Insert("TestCollection", payload)
+	if err != nil || objectID != "order-1" {
+		t.Error("Provided objectid not used: ", objectID, err)
+		return
+	}
+
+	_, err = memj.Insert("TestCollection", map[string]interface{}{"objectid": "order-1"})
+	if err != ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got ", err)
+		return
+	}
+
+	_, err = memj.Insert("TestCollection", map[string]interface{}{"objectid": 1})
+	if err == nil {
+		t.Error("Expected error for non-string objectid")
+		return
+	}
+
+	// objectid of deleted document can be used again
+	memj.Delete("TestCollection", "order-1")
+	if _, err := memj.Insert("TestCollection", payload); err != nil {
+		t.Error("Error inserting deleted objectid: ", err)
+		return
+	}
+
+	// payload is not modified by Insert
+	other := map[string]interface{}{"Name": "Echidna"}
+	memj.Insert("TestCollection", other)
+	if _, ok := other["objectid"]; ok {
+		t.Error("Payload modified by Insert")
+		return
+	}
+}
+
+func TestUpdatePrimaryKey(t *testing.T) {
+	memj, _ := New()
+	memj.Insert("TestCollection", map[string]interface{}{"objectid": "a"})
+
+	_, err := memj.Update("TestCollection", "a", map[string]interface{}{"objectid": "b"})
+	if err != ErrPrimaryKeyChange {
+		t.Error("Expected ErrPrimaryKeyChange, got ", err)
+		return
+	}
+
+	// setting the same value is not a change
+	_, err = memj.Update("TestCollection", "a", map[string]interface{}{"objectid": "a", "Name": "Platypus"})
+	if err != nil {
+		t.Error("Error updating document: ", err)
+		return
+	}
+
+	_, _, err = memj.QueryAndUpdate("TestCollection", map[string]interface{}{"Name": "Platypus"}, map[string]interface{}{"objectid": "c"}, NoLimit)
+	if err != ErrPrimaryKeyChange {
+		t.Error("Expected ErrPrimaryKeyChange from QueryAndUpdate, got ", err)
+		return
+	}
+
+	if _, err := memj.Find("TestCollection", "a"); err != nil {
+		t.Error("Document not found by unchanged primary key: ", err)
+		return
+	}
+}
+
+func TestSetPrimaryKey(t *testing.T) {
+	memj, _ := New()
+	for _, name := range []string{"", "a.b", "$id"} {
+		if err := memj.SetPrimaryKey(name); err == nil {
+			t.Error("Expected error for primary key ", name)
+			return
+		}
+	}
+
+	if err := memj.SetPrimaryKey("_id"); err != nil {
+		t.Error("Error setting primary key: ", err)
+		return
+	}
+
+	objectID, _ := memj.Insert("TestCollection", map[string]interface{}{"Name": "Platypus"})
+	document, err := memj.Find("TestCollection", objectID)
+	if err != nil || document["_id"] != objectID {
+		t.Error("Primary key not stored in _id: ", document, err)
+		return
+	}
+	if _, ok := document["objectid"]; ok {
+		t.Error("objectid stored with custom primary key")
+		return
+	}
+
+	_, err = memj.Insert("TestCollection", map[string]interface{}{"_id": objectID})
+	if err != ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got ", err)
+		return
+	}
+
+	if _, err := memj.Insert("TestCollection", map[string]interface{}{"objectid": objectID}); err != nil {
+		t.Error("objectid treated as primary key: ", err)
+		return
+	}
+
+	_, err = memj.Update("TestCollection", objectID, map[string]interface{}{"_id": "other"})
+	if err != ErrPrimaryKeyChange {
+		t.Error("Expected ErrPrimaryKeyChange, got ", err)
+		return
+	}
+
+	if isDeleted, _ := memj.Delete("TestCollection", objectID); !isDeleted {
+		t.Error("Document not deleted by primary key")
+		return
+	}
+
+	if err := memj.SetPrimaryKey("id"); err == nil {
+		t.Error("Expected error changing primary key of store with collections")
+		return
+	}
+}
+
 func TestFind(t *testing.T) {
 	var jsonTestPayload = []byte(`{"Name": "Platypus", "Order": "Monotremata"}`)
 
@@ -1885,7 +2005,7 @@ func TestQueryAndUpdate(t *testing.T) {
 		return
 	}
 
-	_, isUpdated, err := memj.QueryAndUpdate("TestCollection", queryPayload, queryUpdatePayload, NoLimit)
+	updated, isUpdated, err := memj.QueryAndUpdate("TestCollection", queryPayload, queryUpdatePayload, NoLimit)
 
 	if err != nil {
 		t.Error("Error: ", err)
@@ -1897,6 +2017,11 @@ func TestQueryAndUpdate(t *testing.T) {
 		return
 	}
 
+	if len(updated) != 1 || updated[0]["Order"] != "Fish-77" {
+		t.Error("Expected updated document returned, got ", updated)
+		return
+	}
+
 	var jsonQuery2 = []byte(`{"Order": "Fish-77"}`)
 	var queryPayload2 map[string]interface{}
 	err = json.Unmarshal(jsonQuery2, &queryPayload2)
@@ -3092,3 +3217,146 @@ func TestComparisonWithSelection(t *testing.T) {
 		return
 	}
 }
+
+func TestComparisonFailsWithMatchingSelection(t *testing.T) {
+	memj, _ := New()
+
+	for i := 0; i < 100; i++ {
+		payloadText := fmt.Sprintf(`{"OrderID": "id-%d", "OrderPrice": %d}`, i, i)
+		var jsonTestPayload = []byte(payloadText)
+
+		var payload map[string]interface{}
+		err := json.Unmarshal(jsonTestPayload, &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return
+		}
+
+		_, err = memj.Insert("TestCollection", payload)
+
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	var jsonQuery = []byte(`{"OrderPrice": {"$gte": 50}, "OrderID": "id-7"}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	for i := 0; i < 20; i++ {
+		documents, err := memj.Query("TestCollection", queryPayload, NoLimit)
+
+		if err != nil {
+			t.Error("Error: ", err)
+			return
+		}
+
+		if len(documents) != 0 {
+			t.Error("Incorrect number of documents returned")
+			return
+		}
+	}
+}
+
+func TestQueryOperatorsOnNestedFields(t *testing.T) {
+	memj, _ := New()
+
+	for i := 0; i < 100; i++ {
+		payloadText := fmt.Sprintf(`{"Name": "FindMeOut%d", "Order": {"OrderID": %d, "Items": [{"Sku": "sku-%d", "Qty": %d}]}}`, i, i, i%5, i)
+		var jsonTestPayload = []byte(payloadText)
+
+		var payload map[string]interface{}
+		err := json.Unmarshal(jsonTestPayload, &payload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return
+		}
+
+		_, err = memj.Insert("TestCollection", payload)
+
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	var jsonQuery = []byte(`{"$and": [
+		{"Order.OrderID": {"$in": [3, 8, 13, 14]}},
+		{"Order.Items": {"$elemMatch": {"Sku": "sku-3", "Qty": {"$gt": 5}}}}
+	]}`)
+	var queryPayload map[string]interface{}
+	err := json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	documents, err := memj.Query("TestCollection", queryPayload, NoLimit)
+
+	if err != nil {
+		t.Error("Error: ", err)
+		return
+	}
+
+	if len(documents) != 2 {
+		t.Error("Incorrect number of documents returned")
+		return
+	}
+}
+
+func TestQueryInvalidOperatorOperand(t *testing.T) {
+	memj, _ := New()
+
+	var payload = map[string]interface{}{"Name": "FindMeOut"}
+	_, err := memj.Insert("TestCollection", payload)
+
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	for _, jsonQuery := range []string{
+		`{"Name": {"$in": "FindMeOut"}}`,
+		`{"Name": {"$exists": 1}}`,
+		`{"Name": {"$regex": "("}}`,
+		`{"Name": {"$not": "FindMeOut"}}`,
+	} {
+		var queryPayload map[string]interface{}
+		err := json.Unmarshal([]byte(jsonQuery), &queryPayload)
+
+		if err != nil {
+			t.Error("Error unmarshalling: ", err)
+			return
+		}
+
+		_, err = memj.Query("TestCollection", queryPayload, NoLimit)
+
+		if err == nil {
+			t.Error("Invalid operand but no error: ", jsonQuery)
+			return
+		}
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	memj, _ := New()
+	insertOrders(t, memj, 3)
+
+	if results, _ := memj.Query("TestCollection", MatchAll(), NoLimit); len(results) != 3 {
+		t.Error("Expected all documents, got ", len(results))
+		return
+	}
+	if results, _ := memj.Query("TestCollection", map[string]interface{}{}, NoLimit); len(results) != 0 {
+		t.Error("Expected empty query to match none, got ", len(results))
+		return
+	}
+}
@@ -0,0 +1,186 @@
+package memj
+
+import (
+	"errors"
+	"time"
+)
+
+// Option - configures MemJ created by New
+type Option func(*MemJ) error
+
+// Logger - receives log messages of MemJ, satisfied by *log.Logger
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// Operation - measurement of operation reported to Metrics
+type Operation struct {
+	// Name - method name without Ctx suffix, for example "Query"
+	Name       string
+	Collection string
+	// Duration - time from start to end of operation measured with Clock of
+	// MemJ, includes waiting for locks
+	Duration time.Duration
+	// Documents - number of documents returned, counted or written
+	Documents int
+	Err       error
+}
+
+// Metrics - hook called after every document operation
+//
+// Observe is called from the goroutine that ran the operation after its
+// locks are released, so it may be called concurrently.
+type Metrics interface {
+	Observe(operation Operation)
+}
+
+// WithIDGenerator - generate objectid of inserted documents with generator,
+// NewUUIDv4Generator by default
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(m *MemJ) error {
+		return m.SetIDGenerator(generator)
+	}
+}
+
+// WithPrimaryKey - store id of documents in field name instead of
+// DefaultPrimaryKey, for example "_id" or "id"
+//
+// Values of the primary key are strings and cannot be changed by updates.
+func WithPrimaryKey(name string) Option {
+	return func(m *MemJ) error {
+		return m.SetPrimaryKey(name)
+	}
+}
+
+// WithShards - split documents of every collection into n shards by hash of
+// primary key, 1 keeps collections unsharded
+//
+// Inserts, updates and deletes of single documents lock only their shard, so
+// writes to different shards of a collection run concurrently, and queries
+// match shards in parallel.  Operations on whole collection still lock all
+// of it.  Capped collections are never sharded.
+func WithShards(n int) Option {
+	return func(m *MemJ) error {
+		if n < 1 {
+			return errors.New("Number of shards must be at least 1")
+		}
+		m.shards = n
+		return nil
+	}
+}
+
+// WithParallelQuery - scan collections of at least minDocuments documents
+// by GOMAXPROCS workers when matching queries
+//
+// Matches keep insertion order and limit, workers stop once the first limit
+// matches are found.  Starting workers costs more than serial scan of small
+// collections, run BenchmarkQuery to find where parallel scan gets faster on
+// your queries and machine.  Shards of sharded collection are already
+// matched in parallel and are scanned serially.
+func WithParallelQuery(minDocuments int) Option {
+	return func(m *MemJ) error {
+		if minDocuments < 1 {
+			return errors.New("Minimum number of documents for parallel query must be at least 1")
+		}
+		m.parallelQuery = minDocuments
+		return nil
+	}
+}
+
+// WithCopyOnRead - return deep copies of stored documents from reads
+//
+// By default reads return the stored documents themselves, which is faster
+// but lets callers modify the store without locking.  With copy-on-read
+// changes to returned documents are never seen by the store.
+func WithCopyOnRead() Option {
+	return func(m *MemJ) error {
+		m.copyOnRead = true
+		return nil
+	}
+}
+
+// WithPersistence - load snapshot file at path in New when it exists and save
+// all collections to it in Close
+func WithPersistence(path string) Option {
+	return func(m *MemJ) error {
+		if path == "" {
+			return errors.New("Persistence path must not be empty")
+		}
+		m.persistencePath = path
+		return nil
+	}
+}
+
+// WithClock - use clock as source of current time instead of system clock
+func WithClock(clock Clock) Option {
+	return func(m *MemJ) error {
+		if clock == nil {
+			return errors.New("Clock must not be nil")
+		}
+		m.clock = clock
+		return nil
+	}
+}
+
+// WithLogger - write log messages, such as validation warnings, to logger
+// instead of standard logger
+func WithLogger(logger Logger) Option {
+	return func(m *MemJ) error {
+		if logger == nil {
+			return errors.New("Logger must not be nil")
+		}
+		m.logger = logger
+		return nil
+	}
+}
+
+// WithMetrics - report measurements of document operations to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(m *MemJ) error {
+		if metrics == nil {
+			return errors.New("Metrics must not be nil")
+		}
+		m.metrics = metrics
+		return nil
+	}
+}
+
+// WithReaperInterval - remove expired documents of collections with TTL every
+// interval, DefaultReaperInterval by default
+func WithReaperInterval(interval time.Duration) Option {
+	return func(m *MemJ) error {
+		if interval <= 0 {
+			return errors.New("Reaper interval must be positive")
+		}
+		m.reaperInterval = interval
+		return nil
+	}
+}
+
+// now - current time of clock
+func (m *MemJ) now() time.Time {
+	return m.clock.Now()
+}
+
+// observe - report operation started at start to metrics
+func (m *MemJ) observe(name, collection string, start time.Time, documents int, err error) {
+	if m.metrics == nil {
+		return
+	}
+
+	m.metrics.Observe(Operation{
+		Name:       name,
+		Collection: collection,
+		Duration:   m.now().Sub(start),
+		Documents:  documents,
+		Err:        err,
+	})
+}
+
+// boolCount - 1 for true, number of documents of single document operations
+func boolCount(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
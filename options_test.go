@@ -0,0 +1,163 @@
+package memj
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+type testMetrics struct {
+	lock       sync.Mutex
+	operations []Operation
+}
+
+func (m *testMetrics) Observe(operation Operation) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.operations = append(m.operations, operation)
+}
+
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestNewInvalidOptions(t *testing.T) {
+	for _, opt := range []Option{WithClock(nil), WithLogger(nil), WithMetrics(nil), WithPersistence("")} {
+		if _, err := New(opt); err == nil {
+			t.Error("Expected error for invalid option")
+			return
+		}
+	}
+}
+
+func TestWithCopyOnRead(t *testing.T) {
+	memj, _ := New(WithCopyOnRead())
+	objectID, _ := memj.Insert("Orders", map[string]interface{}{"Name": "Platypus", "Tags": []interface{}{"a"}})
+
+	document, _ := memj.Find("Orders", objectID)
+	document["Name"] = "Changed"
+	document["Tags"].([]interface{})[0] = "changed"
+
+	documents, _ := memj.Query("Orders", map[string]interface{}{"Name": "Platypus"}, NoLimit)
+	if len(documents) != 1 || documents[0]["Tags"].([]interface{})[0] != "a" {
+		t.Error("Stored document changed through Find result: ", documents)
+		return
+	}
+	documents[0]["Name"] = "Changed"
+
+	all, _ := memj.FindAll("Orders")
+	all[0]["Name"] = "Changed"
+
+	cursor, _ := memj.QueryCursor("Orders", map[string]interface{}{"Name": "Platypus"}, CursorOptions{})
+	for cursor.Next() {
+		cursor.Document()["Name"] = "Changed"
+	}
+
+	if count, _ := memj.Count("Orders", map[string]interface{}{"Name": "Platypus"}); count != 1 {
+		t.Error("Stored document changed through read results")
+		return
+	}
+
+	// without copy-on-read the stored document is returned
+	shared, _ := New()
+	objectID, _ = shared.Insert("Orders", map[string]interface{}{"Name": "Platypus"})
+	first, _ := shared.Find("Orders", objectID)
+	second, _ := shared.Find("Orders", objectID)
+	first["Name"] = "Changed"
+	if second["Name"] != "Changed" {
+		t.Error("Expected stored document without copy-on-read")
+		return
+	}
+}
+
+func TestWithPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	memj, err := New(WithPersistence(path))
+	if err != nil {
+		t.Error("Error creating MemJ with missing snapshot: ", err)
+		return
+	}
+
+	objectID, _ := memj.Insert("Orders", map[string]interface{}{"Name": "Platypus"})
+	if err := memj.Close(); err != nil {
+		t.Error("Error closing MemJ: ", err)
+		return
+	}
+
+	reopened, err := New(WithPersistence(path))
+	if err != nil {
+		t.Error("Error reopening MemJ: ", err)
+		return
+	}
+	if _, err := reopened.Find("Orders", objectID); err != nil {
+		t.Error("Document not persisted: ", err)
+		return
+	}
+
+	os.WriteFile(path, []byte("not json"), 0644)
+	if _, err := New(WithPersistence(path)); err == nil {
+		t.Error("Expected error loading invalid snapshot")
+		return
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	metrics := &testMetrics{}
+	memj, _ := New(WithClock(&testClock{}), WithMetrics(metrics))
+
+	memj.Insert("Orders", map[string]interface{}{"Price": 1})
+	memj.Insert("Orders", map[string]interface{}{"Price": 2})
+	memj.Query("Orders", map[string]interface{}{"Price": map[string]interface{}{GTE: 1}}, NoLimit)
+	memj.Find("Orders", "missing")
+
+	expected := []Operation{
+		{Name: "Insert", Collection: "Orders", Duration: time.Second, Documents: 1},
+		{Name: "Insert", Collection: "Orders", Duration: time.Second, Documents: 1},
+		{Name: "Query", Collection: "Orders", Duration: time.Second, Documents: 2},
+		{Name: "Find", Collection: "Orders", Duration: time.Second, Documents: 0, Err: ErrNotFound},
+	}
+	if len(metrics.operations) != len(expected) {
+		t.Error("Incorrect operations observed: ", metrics.operations)
+		return
+	}
+	for i, operation := range metrics.operations {
+		if operation != expected[i] {
+			t.Errorf("Incorrect operation %d: %+v", i, operation)
+			return
+		}
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := &testLogger{}
+	memj, _ := New(WithLogger(logger))
+	memj.SetValidator("Orders", map[string]interface{}{"required": []interface{}{"Price"}}, ValidatorOptions{Action: ValidationWarn})
+
+	if _, err := memj.Insert("Orders", map[string]interface{}{"Name": "Platypus"}); err != nil {
+		t.Error("Error inserting with warning validator: ", err)
+		return
+	}
+
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "missing required field Price") {
+		t.Error("Validation warning not logged: ", logger.messages)
+		return
+	}
+}
@@ -0,0 +1,134 @@
+package memj
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelChunks - number of chunks per worker of parallel scan, more chunks
+// than workers let scan with limit stop once the first chunks have enough
+// matches
+const parallelChunks = 4
+
+// matchChunk - result of scanning chunk of documents in parallel
+type matchChunk struct {
+	matches []map[string]interface{}
+	err     error
+	done    bool
+}
+
+// isParallel - scan of documents runs in parallel, see WithParallelQuery
+func (m *MemJ) isParallel(documents []map[string]interface{}) bool {
+	return m.parallelQuery > 0 && len(documents) >= m.parallelQuery && runtime.GOMAXPROCS(0) > 1
+}
+
+// matchParallel - documents matching query in insertion order, at most limit
+// of them unless limit is NoLimit, scanned in chunks by GOMAXPROCS workers
+//
+// Workers take chunks in order.  As soon as the chunks up to one of them hold
+// limit matches, or it fails, the chunks after it are skipped and the ones
+// being scanned stop, their matches would not be returned by serial scan.
+func (m *MemJ) matchParallel(ctx context.Context, documents []map[string]interface{}, query *PreparedQuery, limit int) ([]map[string]interface{}, error) {
+	workers := runtime.GOMAXPROCS(0)
+	size := (len(documents) + workers*parallelChunks - 1) / (workers * parallelChunks)
+	chunks := make([]matchChunk, (len(documents)+size-1)/size)
+	if workers > len(chunks) {
+		workers = len(chunks)
+	}
+
+	// stop - chunks from stop on are not needed
+	var stop atomic.Int64
+	stop.Store(int64(len(chunks)))
+	stopAfter := func(i int) {
+		for {
+			current := stop.Load()
+			if int64(i+1) >= current || stop.CompareAndSwap(current, int64(i+1)) {
+				return
+			}
+		}
+	}
+
+	// lock - guards done and found, the number of chunks done in order and
+	// their matches
+	var lock sync.Mutex
+	done, found := 0, 0
+	finish := func(i int) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		chunks[i].done = true
+		if chunks[i].err != nil || (limit != NoLimit && len(chunks[i].matches) >= limit) {
+			stopAfter(i)
+		}
+		for done < len(chunks) && chunks[done].done {
+			found += len(chunks[done].matches)
+			if limit != NoLimit && found >= limit {
+				stopAfter(done)
+			}
+			done++
+		}
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if int64(i) >= stop.Load() {
+					return
+				}
+
+				chunk := &chunks[i]
+				start, end := i*size, (i+1)*size
+				if end > len(documents) {
+					end = len(documents)
+				}
+				for index := start; index < end; index++ {
+					if (index-start)%contextCheckInterval == 0 {
+						if int64(i) >= stop.Load() {
+							break
+						}
+						if chunk.err = ctx.Err(); chunk.err != nil {
+							break
+						}
+					}
+
+					isFound, err := query.Match(documents[index])
+					if err != nil {
+						chunk.err = err
+						break
+					}
+
+					if isFound {
+						chunk.matches = append(chunk.matches, documents[index])
+						if limit != NoLimit && len(chunk.matches) >= limit {
+							break
+						}
+					}
+				}
+				finish(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result []map[string]interface{}
+	for i := range chunks {
+		for _, document := range chunks[i].matches {
+			result = append(result, document)
+			if limit != NoLimit && len(result) >= limit {
+				return result, nil
+			}
+		}
+		if chunks[i].err != nil {
+			return nil, chunks[i].err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestParallelQuery(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	if _, err := New(WithParallelQuery(0)); err == nil {
+		t.Error("Expected error for parallel query of 0 documents")
+		return
+	}
+
+	serial, _ := New()
+	parallel, _ := New(WithParallelQuery(1))
+	for _, memj := range []*MemJ{serial, parallel} {
+		if !insertOrders(t, memj, 1000) {
+			return
+		}
+	}
+
+	queries := []map[string]interface{}{
+		{"OrderPrice": map[string]interface{}{GTE: 0}},
+		{"OrderPrice": map[string]interface{}{GTE: 990}},
+		{"OrderPrice": map[string]interface{}{LT: 5}},
+		{"OrderPrice": -1},
+	}
+	for _, query := range queries {
+		for _, limit := range []int{NoLimit, 1, 3, 100, 2000} {
+			expected, _ := serial.Query("TestCollection", query, limit)
+			results, err := parallel.Query("TestCollection", query, limit)
+			if err != nil {
+				t.Error("Error querying in parallel: ", err)
+				return
+			}
+			if len(results) != len(expected) {
+				t.Error(fmt.Sprintf("Expected %d results of %v limit %d, got %d", len(expected), query, limit, len(results)))
+				return
+			}
+			for i := range results {
+				if results[i]["OrderPrice"] != expected[i]["OrderPrice"] {
+					t.Error("Expected order of serial query, got ", results[i]["OrderPrice"], " at ", i)
+					return
+				}
+			}
+		}
+	}
+
+	if _, err := parallel.Query("TestCollection", map[string]interface{}{"$and": "invalid"}, NoLimit); err == nil {
+		t.Error("Expected error of invalid query")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := parallel.QueryCtx(ctx, "TestCollection", queries[0], NoLimit); !errors.Is(err, context.Canceled) {
+		t.Error("Expected Canceled from parallel query, got ", err)
+		return
+	}
+}
+
+func TestParallelQueryClone(t *testing.T) {
+	memj, _ := New(WithParallelQuery(10))
+	fork, err := memj.Fork()
+	if err != nil {
+		t.Error("Error forking: ", err)
+		return
+	}
+
+	if fork.parallelQuery != 10 {
+		t.Error("Expected fork to keep parallel query, got ", fork.parallelQuery)
+		return
+	}
+}
+
+// BenchmarkQuery - serial and parallel scans of growing collections, the
+// size where parallel gets faster is the one to pass to WithParallelQuery
+func BenchmarkQuery(b *testing.B) {
+	query := map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 90}}
+
+	for _, size := range []int{100, 1000, 10000, 100000} {
+		serial, _ := New()
+		parallel, _ := New(WithParallelQuery(1))
+		for _, memj := range []*MemJ{serial, parallel} {
+			for i := 0; i < size; i++ {
+				memj.Insert("Orders", map[string]interface{}{"OrderPrice": float64(i % 100)})
+			}
+		}
+
+		for _, mode := range []struct {
+			name string
+			memj *MemJ
+		}{{"serial", serial}, {"parallel", parallel}} {
+			b.Run(fmt.Sprintf("%s/%d", mode.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					mode.memj.Query("Orders", query, NoLimit)
+				}
+			})
+			b.Run(fmt.Sprintf("%s-limit/%d", mode.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					mode.memj.Query("Orders", query, 10)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package memj
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Keys of snapshot object
+const (
+	snapshotCollections = "collections"
+	snapshotPrimaryKey  = "primaryKey"
+)
+
+// Save - write snapshot of all collections to w
+//
+// Snapshot is a json object {"primaryKey": "objectid", "collections":
+// {"name": [documents...]}} written with MarshalExtendedJSON so Load restores
+// the same value types.  Validators are not part of snapshot.
+func (m *MemJ) Save(w io.Writer) error {
+	return m.SaveCtx(context.Background(), w)
+}
+
+// SaveCtx - like Save but takes context
+func (m *MemJ) SaveCtx(ctx context.Context, w io.Writer) error {
+	names := m.ListCollections()
+
+	collections := make(map[string]bool, len(names))
+	for _, name := range names {
+		collections[name] = true
+	}
+	versions, err := m.readCollections(ctx, collections)
+	if err != nil {
+		return err
+	}
+
+	primaryKey, err := json.Marshal(m.primaryKey)
+	if err != nil {
+		return err
+	}
+
+	buf := bufio.NewWriter(w)
+	buf.WriteString(`{"` + snapshotPrimaryKey + `":`)
+	buf.Write(primaryKey)
+	buf.WriteString(`,"` + snapshotCollections + `":{`)
+	written := 0
+	for _, name := range names {
+		// dropped after names were listed
+		if !versions[name].exists() {
+			continue
+		}
+		if written > 0 {
+			buf.WriteByte(',')
+		}
+		written++
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		buf.Write(key)
+		buf.WriteString(":[")
+
+		for j, document := range versions[name].all() {
+			if err := checkContext(ctx, j); err != nil {
+				return err
+			}
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			encoded, err := MarshalExtendedJSON(document)
+			if err != nil {
+				return err
+			}
+			buf.WriteString("\n")
+			buf.Write(encoded)
+		}
+		buf.WriteString("]")
+	}
+	buf.WriteString("}}\n")
+
+	return buf.Flush()
+}
+
+// Load - read snapshot written by Save from r
+//
+// Collections in snapshot replace collections with the same name, other
+// collections are left as they are.  Documents keep their primary key and are
+// not validated, capped collections keep only their newest documents.
+// Snapshot must use the same primary key as m.
+func (m *MemJ) Load(r io.Reader) error {
+	return m.LoadCtx(context.Background(), r)
+}
+
+// LoadCtx - like Load but takes context
+func (m *MemJ) LoadCtx(ctx context.Context, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := ParseJSON(data)
+	if err != nil {
+		return err
+	}
+
+	collections, ok := snapshot[snapshotCollections].(map[string]interface{})
+	if !ok {
+		return errors.New("Invalid snapshot")
+	}
+
+	// snapshots written before primary key was recorded use the default
+	primaryKey := DefaultPrimaryKey
+	if value, ok := snapshot[snapshotPrimaryKey]; ok {
+		primaryKey, ok = value.(string)
+		if !ok {
+			return errors.New("Invalid snapshot primary key")
+		}
+	}
+	if primaryKey != m.primaryKey {
+		return errors.New("Snapshot primary key " + primaryKey + " does not match " + m.primaryKey)
+	}
+
+	loaded := make(map[string][]map[string]interface{}, len(collections))
+	for name, value := range collections {
+		list, ok := value.([]interface{})
+		if !ok {
+			return errors.New("Invalid snapshot collection " + name)
+		}
+
+		documents := make([]map[string]interface{}, 0, len(list))
+		ids := make(map[string]bool, len(list))
+		for _, item := range list {
+			document, ok := item.(map[string]interface{})
+			if !ok {
+				return errors.New("Invalid snapshot document in " + name)
+			}
+			objectID, ok := document[primaryKey].(string)
+			if !ok {
+				return errors.New("Snapshot document without " + primaryKey + " in " + name)
+			}
+			if ids[objectID] {
+				return errors.New("Duplicate " + primaryKey + " " + objectID + " in snapshot collection " + name)
+			}
+			ids[objectID] = true
+			documents = append(documents, document)
+		}
+		loaded[name] = documents
+	}
+
+	names := make(map[string]bool, len(loaded))
+	for name := range loaded {
+		names[name] = true
+	}
+	locked, unlock, err := m.lockCollections(ctx, names, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for name, documents := range loaded {
+		locked[name].setDocuments(documents)
+		m.resetCapped(locked[name])
+	}
+
+	return nil
+}
+
+// SaveFile - write snapshot to file at path
+//
+// Snapshot is written to temporary file in the same directory and renamed
+// over path so readers never see partially written file.
+func (m *MemJ) SaveFile(path string) error {
+	return m.SaveFileCtx(context.Background(), path)
+}
+
+// SaveFileCtx - like SaveFile but takes context
+func (m *MemJ) SaveFileCtx(ctx context.Context, path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = file.Chmod(0644)
+	if err == nil {
+		err = m.SaveCtx(ctx, file)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// LoadFile - read snapshot from file at path
+func (m *MemJ) LoadFile(path string) error {
+	return m.LoadFileCtx(context.Background(), path)
+}
+
+// LoadFileCtx - like LoadFile but takes context
+func (m *MemJ) LoadFileCtx(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return m.LoadCtx(ctx, file)
+}
@@ -0,0 +1,166 @@
+package memj
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	memj, _ := New()
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	objectID, err := memj.Insert("Orders", map[string]interface{}{
+		"OrderID": "id-1",
+		"Price":   10.0,
+		"Count":   int64(3),
+		"Created": created,
+		"Items":   []interface{}{map[string]interface{}{"Name": "pen", "Weight": 0.5}},
+	})
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+	memj.Insert("Customers", map[string]interface{}{"Name": "Rob"})
+
+	var buf bytes.Buffer
+	err = memj.Save(&buf)
+	if err != nil {
+		t.Error("Error saving snapshot: ", err)
+		return
+	}
+
+	restored, _ := New()
+	err = restored.Load(&buf)
+	if err != nil {
+		t.Error("Error loading snapshot: ", err)
+		return
+	}
+
+	collections := restored.ListCollections()
+	if len(collections) != 2 || collections[0] != "Customers" || collections[1] != "Orders" {
+		t.Error("Incorrect collections restored: ", collections)
+		return
+	}
+
+	document, err := restored.Find("Orders", objectID)
+	if err != nil {
+		t.Error("Document not restored with its objectid: ", err)
+		return
+	}
+
+	if price, ok := document["Price"].(float64); !ok || price != 10.0 {
+		t.Errorf("Float field not restored as float64: %#v", document["Price"])
+		return
+	}
+
+	if count, ok := document["Count"].(int64); !ok || count != 3 {
+		t.Errorf("Integer field not restored as int64: %#v", document["Count"])
+		return
+	}
+
+	if date, ok := document["Created"].(time.Time); !ok || !date.Equal(created) {
+		t.Errorf("Date field not restored as time.Time: %#v", document["Created"])
+		return
+	}
+
+	documents, err := restored.QueryJSON("Orders", `{"Items": {"$elemMatch": {"Name": "pen"}}}`, NoLimit)
+	if err != nil || len(documents) != 1 {
+		t.Error("Nested document not restored")
+		return
+	}
+}
+
+func TestLoadInvalidSnapshot(t *testing.T) {
+	memj, _ := New()
+
+	for _, snapshot := range []string{
+		`{"Orders": []}`,
+		`{"collections": {"Orders": {}}}`,
+		`{"collections": {"Orders": [1]}}`,
+		`{"collections": {"Orders": [{"Name": "no id"}]}}`,
+		`{"collections": {"Orders": [{"objectid": "a"}, {"objectid": "a"}]}}`,
+	} {
+		err := memj.Load(bytes.NewBufferString(snapshot))
+		if err == nil {
+			t.Error("Expected error loading snapshot ", snapshot)
+			return
+		}
+	}
+
+	if len(memj.ListCollections()) != 0 {
+		t.Error("Invalid snapshot partially loaded")
+		return
+	}
+}
+
+func TestSaveLoadPrimaryKey(t *testing.T) {
+	memj, _ := New()
+	memj.SetPrimaryKey("id")
+	objectID, _ := memj.Insert("Orders", map[string]interface{}{"Name": "Platypus"})
+
+	var buf bytes.Buffer
+	if err := memj.Save(&buf); err != nil {
+		t.Error("Error saving snapshot: ", err)
+		return
+	}
+
+	loaded, _ := New()
+	loaded.SetPrimaryKey("id")
+	if err := loaded.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Error("Error loading snapshot: ", err)
+		return
+	}
+	if _, err := loaded.Find("Orders", objectID); err != nil {
+		t.Error("Document not found after load: ", err)
+		return
+	}
+
+	other, _ := New()
+	if err := other.Load(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("Expected error loading snapshot with different primary key")
+		return
+	}
+
+	// snapshot without primary key uses objectid
+	if err := other.Load(bytes.NewBufferString(`{"collections": {"Orders": [{"objectid": "a"}]}}`)); err != nil {
+		t.Error("Error loading snapshot without primary key: ", err)
+		return
+	}
+}
+
+func TestSaveLoadFile(t *testing.T) {
+	memj, _ := New()
+
+	for i := 0; i < 10; i++ {
+		memj.Insert("Orders", map[string]interface{}{"OrderID": i})
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	err := memj.SaveFile(path)
+	if err != nil {
+		t.Error("Error saving snapshot file: ", err)
+		return
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Error("Temporary snapshot file left behind")
+		return
+	}
+
+	restored, _ := New()
+	err = restored.LoadFile(path)
+	if err != nil {
+		t.Error("Error loading snapshot file: ", err)
+		return
+	}
+
+	count, _ := restored.Count("Orders", nil)
+	if count != 10 {
+		t.Error("Incorrect number of documents restored")
+		return
+	}
+}
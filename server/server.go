@@ -0,0 +1,217 @@
+// Package server exposes collections of a MemJ store over HTTP so that tests
+// written in other languages can share the same in-memory fixtures.
+//
+// Routes:
+//
+//	GET    /collections                    list collection names
+//	GET    /collections/{collection}       all documents in collection
+//	POST   /collections/{collection}       insert document, returns its primary key
+//	POST   /collections/{collection}/query query with JSON body, optional ?limit=n
+//	GET    /collections/{collection}/{id}  find document by primary key
+//	PATCH  /collections/{collection}/{id}  update fields of document, also PUT
+//	DELETE /collections/{collection}/{id}  delete document
+//
+// Insert returns the id under the primary key field of the store, such as
+// {"objectid": "id"}.  Query with an empty body {} returns all documents, like
+// an empty filter of MongoDB.  Errors are returned as {"error": "message"}
+// with a status code derived from the store error.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/robjsliwa/memj"
+)
+
+// maxBodySize - largest accepted request body
+const maxBodySize = 16 << 20
+
+// Server - http.Handler serving collections of db
+type Server struct {
+	db  *memj.MemJ
+	mux *http.ServeMux
+}
+
+// New - create handler serving collections of db, it can be passed directly
+// to httptest.NewServer or http.ListenAndServe
+func New(db *memj.MemJ) *Server {
+	s := &Server{db: db, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /collections", s.listCollections)
+	s.mux.HandleFunc("GET /collections/{collection}", s.findAll)
+	s.mux.HandleFunc("POST /collections/{collection}", s.insert)
+	s.mux.HandleFunc("POST /collections/{collection}/query", s.query)
+	s.mux.HandleFunc("GET /collections/{collection}/{id}", s.find)
+	s.mux.HandleFunc("PATCH /collections/{collection}/{id}", s.update)
+	s.mux.HandleFunc("PUT /collections/{collection}/{id}", s.update)
+	s.mux.HandleFunc("DELETE /collections/{collection}/{id}", s.delete)
+
+	return s
+}
+
+// ServeHTTP - serve request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) listCollections(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": s.db.ListCollections()})
+}
+
+func (s *Server) findAll(w http.ResponseWriter, r *http.Request) {
+	documents, err := s.db.FindAllCtx(r.Context(), r.PathValue("collection"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeDocuments(w, documents)
+}
+
+func (s *Server) insert(w http.ResponseWriter, r *http.Request) {
+	payload, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	objectID, err := s.db.InsertCtx(r.Context(), r.PathValue("collection"), payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{s.db.PrimaryKey(): objectID})
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request) {
+	limit := memj.NoLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeErrorStatus(w, http.StatusBadRequest, errors.New("Invalid limit"))
+			return
+		}
+	}
+
+	query, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if len(query) == 0 {
+		query = memj.MatchAll()
+	}
+
+	documents, err := s.db.QueryCtx(r.Context(), r.PathValue("collection"), query, limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeDocuments(w, documents)
+}
+
+func (s *Server) find(w http.ResponseWriter, r *http.Request) {
+	document, err := s.db.FindCtx(r.Context(), r.PathValue("collection"), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	payload, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	isUpdated, err := s.db.UpdateCtx(r.Context(), r.PathValue("collection"), r.PathValue("id"), payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"updated": isUpdated})
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	isDeleted, err := s.db.DeleteCtx(r.Context(), r.PathValue("collection"), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": isDeleted})
+}
+
+// readBody - decode json object from request body, writes error response on failure
+func readBody(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeErrorStatus(w, http.StatusRequestEntityTooLarge, err)
+		return nil, false
+	}
+
+	payload, err := memj.ParseJSON(body)
+	if err != nil {
+		writeErrorStatus(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if payload == nil {
+		writeErrorStatus(w, http.StatusBadRequest, errors.New("Request body must be a JSON object"))
+		return nil, false
+	}
+
+	return payload, true
+}
+
+func writeDocuments(w http.ResponseWriter, documents []map[string]interface{}) {
+	if documents == nil {
+		documents = []map[string]interface{}{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"documents": documents})
+}
+
+// writeError - write store error with status code matching its cause
+func writeError(w http.ResponseWriter, err error) {
+	var validationErr *memj.DocumentValidationError
+
+	switch {
+	case errors.Is(err, memj.ErrNotFound):
+		writeErrorStatus(w, http.StatusNotFound, err)
+
+	case errors.Is(err, memj.ErrDuplicateID):
+		writeErrorStatus(w, http.StatusConflict, err)
+
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		writeErrorStatus(w, http.StatusServiceUnavailable, err)
+
+	case errors.As(err, &validationErr):
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":   err.Error(),
+			"details": validationErr.Errors,
+		})
+
+	default:
+		writeErrorStatus(w, http.StatusBadRequest, err)
+	}
+}
+
+func writeErrorStatus(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]interface{}{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/robjsliwa/memj"
+)
+
+func request(t *testing.T, ts *httptest.Server, method, path, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal("Error creating request: ", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal("Error sending request: ", err)
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatal("Error decoding response: ", err)
+	}
+
+	return resp.StatusCode, result
+}
+
+func TestServerInsertFindUpdateDelete(t *testing.T) {
+	db, _ := memj.New()
+	ts := httptest.NewServer(New(db))
+	defer ts.Close()
+
+	status, result := request(t, ts, "POST", "/collections/Orders", `{"OrderID": "id-1", "OrderPrice": 10}`)
+	if status != http.StatusCreated {
+		t.Error("Unexpected status: ", status, result)
+		return
+	}
+
+	objectID, _ := result["objectid"].(string)
+	if objectID == "" {
+		t.Error("Invalid objectID")
+		return
+	}
+
+	status, result = request(t, ts, "GET", "/collections/Orders/"+objectID, "")
+	if status != http.StatusOK || result["OrderID"] != "id-1" {
+		t.Error("Wrong document returned: ", status, result)
+		return
+	}
+
+	status, result = request(t, ts, "PATCH", "/collections/Orders/"+objectID, `{"OrderPrice": 20}`)
+	if status != http.StatusOK || result["updated"] != true {
+		t.Error("Document not updated: ", status, result)
+		return
+	}
+
+	document, _ := db.Find("Orders", objectID)
+	if document["OrderPrice"] != int64(20) {
+		t.Error("Update not stored")
+		return
+	}
+
+	status, result = request(t, ts, "DELETE", "/collections/Orders/"+objectID, "")
+	if status != http.StatusOK || result["deleted"] != true {
+		t.Error("Document not deleted: ", status, result)
+		return
+	}
+
+	status, result = request(t, ts, "GET", "/collections/Orders/"+objectID, "")
+	if status != http.StatusNotFound || result["error"] != memj.ErrNotFound.Error() {
+		t.Error("Expected not found error: ", status, result)
+		return
+	}
+}
+
+func TestServerCustomPrimaryKey(t *testing.T) {
+	db, _ := memj.New(memj.WithPrimaryKey("id"))
+	ts := httptest.NewServer(New(db))
+	defer ts.Close()
+
+	status, result := request(t, ts, "POST", "/collections/Orders", `{"OrderID": "id-1"}`)
+	objectID, _ := result["id"].(string)
+	if status != http.StatusCreated || objectID == "" || result["objectid"] != nil {
+		t.Error("Expected id under primary key, got ", status, result)
+		return
+	}
+
+	status, result = request(t, ts, "GET", "/collections/Orders/"+objectID, "")
+	if status != http.StatusOK || result["OrderID"] != "id-1" {
+		t.Error("Wrong document returned: ", status, result)
+		return
+	}
+}
+
+func TestServerQueryAndListCollections(t *testing.T) {
+	db, _ := memj.New()
+	ts := httptest.NewServer(New(db))
+	defer ts.Close()
+
+	for _, payload := range []string{`{"Price": 5}`, `{"Price": 15}`, `{"Price": 25}`} {
+		_, err := db.InsertJSON("Orders", payload)
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+	db.InsertJSON("Customers", `{"Name": "Ann"}`)
+
+	status, result := request(t, ts, "POST", "/collections/Orders/query?limit=1", `{"Price": {"$gt": 10}}`)
+	if status != http.StatusOK {
+		t.Error("Unexpected status: ", status, result)
+		return
+	}
+
+	documents, _ := result["documents"].([]interface{})
+	if len(documents) != 1 || documents[0].(map[string]interface{})["Price"] != float64(15) {
+		t.Error("Wrong documents returned: ", result)
+		return
+	}
+
+	status, result = request(t, ts, "POST", "/collections/Orders/query", `{}`)
+	documents, _ = result["documents"].([]interface{})
+	if status != http.StatusOK || len(documents) != 3 {
+		t.Error("Expected empty query to return all documents: ", result)
+		return
+	}
+
+	status, result = request(t, ts, "GET", "/collections", "")
+	collections, _ := result["collections"].([]interface{})
+	if status != http.StatusOK || len(collections) != 2 || collections[0] != "Customers" {
+		t.Error("Wrong collections returned: ", result)
+		return
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	db, _ := memj.New()
+	ts := httptest.NewServer(New(db))
+	defer ts.Close()
+
+	schema, _ := memj.ParseJSON(`{"required": ["Name"]}`)
+	db.SetValidator("Users", schema, memj.ValidatorOptions{})
+
+	status, result := request(t, ts, "POST", "/collections/Users", `{"Age": 3}`)
+	if status != http.StatusUnprocessableEntity || result["details"] == nil {
+		t.Error("Expected validation error: ", status, result)
+		return
+	}
+
+	status, result = request(t, ts, "POST", "/collections/Users", `{"Name": `)
+	if status != http.StatusBadRequest || result["error"] == nil {
+		t.Error("Expected bad request: ", status, result)
+		return
+	}
+
+	db.InsertJSON("Users", `{"Name": "Ann", "Age": 3}`)
+	status, result = request(t, ts, "POST", "/collections/Users/query", `{"Age": {"$gt": [1]}}`)
+	if status != http.StatusBadRequest || result["error"] == nil {
+		t.Error("Expected bad request: ", status, result)
+		return
+	}
+
+	status, _ = request(t, ts, "POST", "/collections/Users/query?limit=-1", `{}`)
+	if status != http.StatusBadRequest {
+		t.Error("Expected bad request for invalid limit: ", status)
+		return
+	}
+}
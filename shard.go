@@ -0,0 +1,325 @@
+package memj
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// store - documents with their insertion sequence numbers, of unsharded
+// collection or of one shard of sharded collection
+type store struct {
+	lock      sync.RWMutex
+	documents []map[string]interface{}
+	// sequences - insertion sequence numbers of documents, ascending
+	sequences []uint64
+	// ids - primary keys of documents
+	ids map[string]bool
+	// published - documents slice is shared with versions read without the
+	// lock, it is copied before its elements are changed
+	published atomic.Bool
+}
+
+// own - copy documents slice shared with versions before its elements are
+// changed, caller holds write lock
+//
+// Appends are not copied, they write past the end of versions.
+func (s *store) own() {
+	if s.published.Load() {
+		documents := make([]map[string]interface{}, len(s.documents), len(s.documents)+1)
+		copy(documents, s.documents)
+		sequences := make([]uint64, len(s.sequences), len(s.sequences)+1)
+		copy(sequences, s.sequences)
+		s.documents, s.sequences = documents, sequences
+		s.published.Store(false)
+	}
+}
+
+// remove - remove document at index with primary key, caller holds write
+// lock
+func (s *store) remove(index int, objectID string) {
+	s.own()
+	s.documents = append(s.documents[:index], s.documents[index+1:]...)
+	s.sequences = append(s.sequences[:index], s.sequences[index+1:]...)
+	delete(s.ids, objectID)
+}
+
+// newCollection - empty collection with number of shards set by WithShards
+func (m *MemJ) newCollection() *collection {
+	c := &collection{primaryKey: m.primaryKey}
+	if m.shards > 1 {
+		c.shards = make([]*store, m.shards)
+		for i := range c.shards {
+			c.shards[i] = &store{}
+		}
+	}
+
+	return c
+}
+
+// stores - stores holding documents of collection
+func (c *collection) stores() []*store {
+	if c.shards == nil {
+		return []*store{&c.store}
+	}
+	return c.shards
+}
+
+// shard - store holding document with primary key
+func (c *collection) shard(objectID string) *store {
+	if c.shards == nil {
+		return &c.store
+	}
+
+	// FNV-1a
+	hash := uint32(2166136261)
+	for i := 0; i < len(objectID); i++ {
+		hash ^= uint32(objectID[i])
+		hash *= 16777619
+	}
+
+	return c.shards[hash%uint32(len(c.shards))]
+}
+
+// lockShard - store of collection holding document with primary key and
+// function unlocking it, caller holds collection lock
+//
+// Shard of sharded collection is write locked and its expired documents are
+// removed, unsharded collection must be write locked by caller.
+func (m *MemJ) lockShard(ctx context.Context, c *collection, objectID string) (*store, func(), error) {
+	s := c.shard(objectID)
+	if c.shards == nil {
+		return s, func() {}, nil
+	}
+
+	err := s.lockContext(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.removeExpiredStore(c, s)
+
+	return s, s.lock.Unlock, nil
+}
+
+// unshard - keep documents of collection in its own store, caller holds
+// collection write lock and replaces documents afterwards
+func (c *collection) unshard() {
+	c.shards = nil
+}
+
+// count - number of documents in collection
+func (c *collection) count() int {
+	count := 0
+	for _, s := range c.stores() {
+		count += len(s.documents)
+	}
+	return count
+}
+
+// each - call fn with store and index of documents of collection in insertion
+// order until it returns false, caller holds collection lock
+func (c *collection) each(fn func(s *store, index int) bool) {
+	eachStore(c.stores(), fn)
+}
+
+// eachStore - call fn with store and index of documents of stores merged in
+// order of their sequence numbers until it returns false
+func eachStore(stores []*store, fn func(s *store, index int) bool) {
+	if len(stores) == 1 {
+		s := stores[0]
+		for index := range s.documents {
+			if !fn(s, index) {
+				return
+			}
+		}
+		return
+	}
+
+	next := make([]int, len(stores))
+	for {
+		first := -1
+		for i, s := range stores {
+			if next[i] == len(s.documents) {
+				continue
+			}
+			if first < 0 || s.sequences[next[i]] < stores[first].sequences[next[first]] {
+				first = i
+			}
+		}
+		if first < 0 {
+			return
+		}
+
+		index := next[first]
+		next[first]++
+		if !fn(stores[first], index) {
+			return
+		}
+	}
+}
+
+// mergeStores - documents of stores in insertion order, at most limit of them
+// unless limit is NoLimit
+func mergeStores(stores []*store, limit int) []map[string]interface{} {
+	var documents []map[string]interface{}
+	eachStore(stores, func(s *store, index int) bool {
+		documents = append(documents, s.documents[index])
+		return limit == NoLimit || len(documents) < limit
+	})
+
+	return documents
+}
+
+// merged - documents of collection in insertion order, caller holds
+// collection write lock
+func (c *collection) merged() []map[string]interface{} {
+	if c.shards == nil {
+		return c.documents
+	}
+	return mergeStores(c.shards, NoLimit)
+}
+
+// all - documents of version in insertion order, shards of version are
+// merged by the first call
+func (c *collection) all() []map[string]interface{} {
+	if c.shards == nil {
+		return c.documents
+	}
+
+	c.merge.Do(func() {
+		c.documents = mergeStores(c.shards, NoLimit)
+	})
+
+	return c.documents
+}
+
+// readShards - versions of shards of collection, caller holds collection
+// read lock
+//
+// All shards are locked while versions are taken so they are from the same
+// point in time.  Writers lock a single shard, so taking them in order cannot
+// deadlock.
+func (m *MemJ) readShards(c *collection) []*store {
+	for _, s := range c.shards {
+		s.lock.RLock()
+	}
+	defer func() {
+		for _, s := range c.shards {
+			s.lock.RUnlock()
+		}
+	}()
+
+	shards := make([]*store, len(c.shards))
+	for i, s := range c.shards {
+		s.published.Store(true)
+		documents, sequences := m.live(c, s)
+		shards[i] = &store{documents: documents, sequences: sequences}
+	}
+
+	return shards
+}
+
+// parallel - call fn for every shard of version in its own goroutine,
+// returns first error in shard order
+func parallel(shards []*store, fn func(i int, s *store) error) error {
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+	for i, s := range shards {
+		wg.Add(1)
+		go func(i int, s *store) {
+			defer wg.Done()
+			errs[i] = fn(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchVersion - documents of version matching query in insertion order,
+// shards of sharded version are matched in parallel
+//
+// Each shard contributes at most limit matches, the first limit of merged
+// matches are among them.
+func (m *MemJ) matchVersion(ctx context.Context, v *collection, query *PreparedQuery, limit int) ([]map[string]interface{}, error) {
+	if v.shards == nil {
+		return m.matchPrepared(ctx, v.documents, query, limit)
+	}
+
+	matches := make([]*store, len(v.shards))
+	err := parallel(v.shards, func(i int, s *store) error {
+		match := &store{}
+		for index, document := range s.documents {
+			if err := checkContext(ctx, index); err != nil {
+				return err
+			}
+
+			isFound, err := query.Match(document)
+			if err != nil {
+				return err
+			}
+
+			if isFound {
+				match.documents = append(match.documents, document)
+				match.sequences = append(match.sequences, s.sequences[index])
+				if limit != NoLimit && len(match.documents) >= limit {
+					break
+				}
+			}
+		}
+		matches[i] = match
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeStores(matches, limit), nil
+}
+
+// countVersion - number of documents of version matching prepared query, nil
+// query counts all, shards of sharded version are counted in parallel
+func (m *MemJ) countVersion(ctx context.Context, v *collection, query *PreparedQuery) (int, error) {
+	if v.shards == nil {
+		return m.countDocuments(ctx, v.documents, query)
+	}
+
+	counts := make([]int, len(v.shards))
+	err := parallel(v.shards, func(i int, s *store) error {
+		var err error
+		counts[i], err = m.countDocuments(ctx, s.documents, query)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, n := range counts {
+		count += n
+	}
+
+	return count, nil
+}
+
+// findVersion - document of version with primary key, only its shard of
+// sharded version is searched
+func (m *MemJ) findVersion(ctx context.Context, v *collection, objectID string) (map[string]interface{}, error) {
+	for index, value := range v.shard(objectID).documents {
+		if err := checkContext(ctx, index); err != nil {
+			return nil, err
+		}
+		if value[m.primaryKey] == objectID {
+			return value, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,213 @@
+package memj
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShards(t *testing.T) {
+	if _, err := New(WithShards(0)); err == nil {
+		t.Error("Expected error for 0 shards")
+		return
+	}
+
+	memj, _ := New(WithShards(4))
+	if !insertOrders(t, memj, 100) {
+		return
+	}
+
+	c := memj.collections["TestCollection"]
+	for i, s := range c.shards {
+		if len(s.documents) == 0 {
+			t.Error("Expected documents in shard ", i)
+			return
+		}
+	}
+
+	all, _ := memj.FindAll("TestCollection")
+	for i, document := range all {
+		if document["OrderPrice"] != float64(i) {
+			t.Error("Expected insertion order, got ", document["OrderPrice"], " at ", i)
+			return
+		}
+	}
+
+	query := map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 10}}
+	results, _ := memj.Query("TestCollection", query, 5)
+	if len(results) != 5 || results[0]["OrderPrice"] != float64(10) || results[4]["OrderPrice"] != float64(14) {
+		t.Error("Expected first 5 matches in insertion order, got ", results)
+		return
+	}
+	if count, _ := memj.Count("TestCollection", query); count != 90 {
+		t.Error("Expected 90 matches, got ", count)
+		return
+	}
+
+	objectID := all[50][memj.primaryKey].(string)
+	if _, err := memj.Update("TestCollection", objectID, map[string]interface{}{"OrderPrice": -1}); err != nil {
+		t.Error("Error updating: ", err)
+		return
+	}
+	if document, _ := memj.Find("TestCollection", objectID); document["OrderPrice"] != -1 {
+		t.Error("Expected updated document, got ", document)
+		return
+	}
+	if _, err := memj.Delete("TestCollection", objectID); err != nil {
+		t.Error("Error deleting: ", err)
+		return
+	}
+	if _, err := memj.Find("TestCollection", objectID); err != ErrNotFound {
+		t.Error("Expected ErrNotFound after delete, got ", err)
+		return
+	}
+
+	updated, _, err := memj.QueryAndUpdate("TestCollection", query, map[string]interface{}{"Shipped": true}, 2)
+	if err != nil || len(updated) != 2 || updated[0]["OrderPrice"] != float64(10) || updated[0]["Shipped"] != true {
+		t.Error("Expected first 2 matches updated, got ", updated, err)
+		return
+	}
+}
+
+func TestShardsCapped(t *testing.T) {
+	memj, _ := New(WithShards(4))
+	if err := memj.CreateCollection("Log", CollectionOptions{MaxDocuments: 3}); err != nil {
+		t.Error("Error creating capped collection: ", err)
+		return
+	}
+	for i := 0; i < 5; i++ {
+		memj.Insert("Log", map[string]interface{}{"Line": i})
+	}
+
+	if memj.collections["Log"].shards != nil {
+		t.Error("Expected capped collection to be unsharded")
+		return
+	}
+	if all, _ := memj.FindAll("Log"); len(all) != 3 || all[0]["Line"] != 2 {
+		t.Error("Expected 3 newest documents, got ", all)
+		return
+	}
+}
+
+func TestShardsTTL(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	memj, _ := New(WithShards(4), WithClock(clock))
+	defer memj.Close()
+
+	memj.SetTTL("Sessions", &TTL{Field: "At", ExpireAfter: time.Hour})
+	for i := 0; i < 8; i++ {
+		memj.Insert("Sessions", map[string]interface{}{"objectid": fmt.Sprint(i), "At": clock.Now()})
+	}
+	clock.Advance(2 * time.Hour)
+
+	if _, err := memj.Find("Sessions", "0"); err != ErrNotFound {
+		t.Error("Expected expired document to be missing, got ", err)
+		return
+	}
+	if isUpdated, err := memj.Update("Sessions", "1", map[string]interface{}{"User": "u"}); isUpdated || err == nil {
+		t.Error("Expected update of expired document to fail, got ", isUpdated, err)
+		return
+	}
+	if isDeleted, _ := memj.Delete("Sessions", "2"); isDeleted {
+		t.Error("Expected delete of expired document to fail")
+		return
+	}
+	if _, err := memj.Insert("Sessions", map[string]interface{}{"objectid": "0", "At": clock.Now()}); err != nil {
+		t.Error("Error inserting id of expired document: ", err)
+		return
+	}
+	if count, _ := memj.Count("Sessions", nil); count != 1 {
+		t.Error("Expected 1 live document, got ", count)
+		return
+	}
+}
+
+func TestShardsCloneAndSnapshot(t *testing.T) {
+	memj, _ := New(WithShards(3))
+	if !seedOrders(t, memj) {
+		return
+	}
+
+	snapshot, _ := memj.Snapshot()
+	defer snapshot.Release()
+	fork, _ := memj.Fork()
+
+	memj.Update("Orders", "1", map[string]interface{}{"Customer.Name": "changed"})
+	memj.Insert("Orders", map[string]interface{}{"objectid": "3"})
+
+	if count, _ := snapshot.Count("Orders", nil); count != 3 {
+		t.Error("Expected 3 documents in snapshot, got ", count)
+		return
+	}
+	if customerName(fork, "1") != "c1" {
+		t.Error("Expected fork unchanged, got ", customerName(fork, "1"))
+		return
+	}
+	if _, err := fork.Insert("Orders", map[string]interface{}{"objectid": "3"}); err != nil {
+		t.Error("Error inserting into fork: ", err)
+		return
+	}
+	if all, _ := fork.FindAll("Orders"); len(all) != 4 || all[3]["objectid"] != "3" {
+		t.Error("Expected insertion order in fork, got ", all)
+		return
+	}
+}
+
+func TestShardsCloneOptions(t *testing.T) {
+	memj, _ := New(WithShards(4))
+	clone, err := memj.Clone()
+	if err != nil {
+		t.Error("Error cloning: ", err)
+		return
+	}
+
+	clone.Insert("Orders", map[string]interface{}{"objectid": "1"})
+	if clone.shards != 4 || len(clone.collections["Orders"].shards) != 4 {
+		t.Error("Expected clone to keep shards, got ", clone.shards)
+		return
+	}
+}
+
+func TestConcurrentShardWriters(t *testing.T) {
+	memj, _ := New(WithShards(8))
+
+	const goroutines = 8
+	const inserts = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < inserts; j++ {
+				objectID, err := memj.Insert("Orders", map[string]interface{}{"Writer": i, "Order": j})
+				if err != nil {
+					t.Error("Error inserting document: ", err)
+					return
+				}
+				if j%4 == 0 {
+					memj.Update("Orders", objectID, map[string]interface{}{"Order": -j})
+				}
+				if j%10 == 0 {
+					memj.Query("Orders", map[string]interface{}{"Writer": i}, NoLimit)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < goroutines; i++ {
+		results, _ := memj.Query("Orders", map[string]interface{}{"Writer": i}, NoLimit)
+		if len(results) != inserts {
+			t.Error(fmt.Sprintf("Lost inserts of writer %d, %d documents", i, len(results)))
+			return
+		}
+		for j, document := range results {
+			if order := document["Order"]; order != j && order != -j {
+				t.Error("Expected insertion order of writer, got ", order, " at ", j)
+				return
+			}
+		}
+	}
+}
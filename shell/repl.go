@@ -0,0 +1,284 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// prompt - shown before each statement in terminal
+const prompt = "memj> "
+
+// maxHistory - number of lines kept in history
+const maxHistory = 1000
+
+// Run - read statements from in and write results to out until exit or end
+// of input
+//
+// When in is a terminal it is switched to raw mode for line editing, history
+// and tab completion, otherwise statements are read line by line without
+// prompt so scripts can be piped into the shell.
+func (s *Shell) Run(in io.Reader, out io.Writer) error {
+	if file, ok := in.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		return s.runTerminal(file, out)
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		if !s.executeLine(out, scanner.Text()) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *Shell) runTerminal(file *os.File, out io.Writer) error {
+	state, err := term.MakeRaw(int(file.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(file.Fd()), state)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{file, out}, prompt)
+	if width, height, err := term.GetSize(int(file.Fd())); err == nil && width > 0 {
+		terminal.SetSize(width, height)
+	}
+
+	history, err := loadHistory(s.HistoryFile)
+	if err != nil {
+		fmt.Fprintln(terminal, "Cannot read history:", err)
+	}
+	terminal.History = history
+
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return s.autoComplete(terminal, line, pos)
+	}
+
+	fmt.Fprintln(terminal, "MemJ shell, type help for list of statements")
+	for {
+		line, err := terminal.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !s.executeLine(terminal, line) {
+			return nil
+		}
+	}
+}
+
+// executeLine - execute statement and print error and timing, returns false
+// on exit
+func (s *Shell) executeLine(w io.Writer, line string) bool {
+	start := time.Now()
+	err := s.Execute(w, line)
+	if errors.Is(err, errExit) {
+		return false
+	}
+	if err != nil {
+		fmt.Fprintln(w, "Error:", err)
+	}
+
+	if s.Timing && strings.TrimSpace(line) != "" {
+		fmt.Fprintf(w, "(%s)\n", time.Since(start).Round(time.Microsecond))
+	}
+
+	return true
+}
+
+// autoComplete - complete word at pos, lists candidates when they do not share
+// a longer prefix
+func (s *Shell) autoComplete(w io.Writer, line string, pos int) (string, int, bool) {
+	start, candidates := s.Complete(line, pos)
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	word := line[start:pos]
+	completion := commonPrefix(candidates)
+	if len(candidates) > 1 && completion == word {
+		fmt.Fprintln(w, strings.Join(candidates, "  "))
+		return "", 0, false
+	}
+
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// Complete - candidates for word ending at pos and index where word starts
+//
+// Collection names are completed after db., method names after
+// db.<collection>. and dotted field paths sampled from collection documents
+// inside quoted object keys of the call arguments.
+func (s *Shell) Complete(line string, pos int) (int, []string) {
+	prefix := line[:pos]
+
+	if !strings.HasPrefix(prefix, "db.") {
+		if strings.ContainsAny(prefix, " ") && !strings.HasPrefix(prefix, "show ") {
+			return pos, nil
+		}
+		return 0, filterPrefix(commands, prefix)
+	}
+
+	open := strings.IndexByte(prefix, '(')
+	if open < 0 {
+		target := prefix[len("db."):]
+		dot := strings.LastIndexByte(target, '.')
+		if dot < 0 {
+			return len("db."), filterPrefix(s.db.ListCollections(), target)
+		}
+		start := len("db.") + dot + 1
+		return start, filterPrefix(collectionMethods, prefix[start:])
+	}
+
+	quote, inString := openString(prefix[open:])
+	if !inString || !isKeyPosition(prefix[:open+quote]) {
+		return pos, nil
+	}
+
+	target := prefix[len("db."):open]
+	dot := strings.LastIndexByte(target, '.')
+	if dot <= 0 {
+		return pos, nil
+	}
+
+	start := open + quote + 1
+	return start, filterPrefix(s.fieldPaths(target[:dot]), prefix[start:])
+}
+
+// openString - index of quote starting unterminated string in text
+func openString(text string) (int, bool) {
+	quote := -1
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote >= 0 && text[i] == '\\':
+			i++
+
+		case text[i] == '"' && quote >= 0:
+			quote = -1
+
+		case text[i] == '"':
+			quote = i
+		}
+	}
+
+	return quote, quote >= 0
+}
+
+// isKeyPosition - string following text is an object key or distinct path
+// rather than a value
+func isKeyPosition(text string) bool {
+	text = strings.TrimRight(text, " \t")
+	if text == "" {
+		return false
+	}
+
+	return strings.ContainsRune("{,(", rune(text[len(text)-1]))
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var result []string
+	for _, value := range values {
+		if strings.HasPrefix(value, prefix) {
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// history - term.History keeping lines in memory and appending them to file
+type history struct {
+	lines []string
+	path  string
+}
+
+// loadHistory - history with lines read from path, empty path keeps history
+// only in memory
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		h.path = ""
+		return h, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+	}
+
+	return h, nil
+}
+
+// Add - add line to history and append it to history file
+func (h *history) Add(line string) {
+	if strings.TrimSpace(line) == "" || strings.ContainsRune(line, '\n') {
+		return
+	}
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+
+	h.lines = append(h.lines, line)
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[1:]
+	}
+
+	if h.path != "" {
+		file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return
+		}
+		file.WriteString(line + "\n")
+		file.Close()
+	}
+}
+
+// Len - number of lines in history
+func (h *history) Len() int {
+	return len(h.lines)
+}
+
+// At - line at index, 0 is the most recent one
+func (h *history) At(index int) string {
+	return h.lines[len(h.lines)-1-index]
+}
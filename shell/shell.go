@@ -0,0 +1,580 @@
+// Package shell implements an interactive mongo-shell-like REPL on top of a
+// MemJ store.
+//
+// Statements:
+//
+//	db.<collection>.find([query], [limit])      also .find(query).limit(n)
+//	db.<collection>.findOne([query])
+//	db.<collection>.count([query])
+//	db.<collection>.distinct(path, [query])
+//	db.<collection>.insertOne(document)
+//	db.<collection>.insertMany([documents])
+//	db.<collection>.updateOne(query, update)    update is {"$set": {...}} or fields
+//	db.<collection>.updateMany(query, update)
+//	db.<collection>.deleteOne(query)
+//	db.<collection>.deleteMany(query)
+//	db.<collection>.drop()
+//	show collections
+//	save [path]                                 write snapshot file
+//	load path                                   read snapshot file
+//	timing on|off
+//	help
+//	exit
+//
+// Arguments are JSON values, extended JSON such as {"$date": "..."} is
+// accepted.  An empty query {} selects all documents.
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robjsliwa/memj"
+)
+
+// sampleSize - number of documents sampled for field path completion
+const sampleSize = 100
+
+// errExit - returned by Execute for exit statement
+var errExit = errors.New("Exit")
+
+const help = `Statements:
+  db.<collection>.find([query], [limit])      print documents, also .find(query).limit(n)
+  db.<collection>.findOne([query])            print first matching document
+  db.<collection>.count([query])              count documents
+  db.<collection>.distinct(path, [query])     print distinct values of field
+  db.<collection>.insertOne(document)         insert document
+  db.<collection>.insertMany([documents])     insert documents
+  db.<collection>.updateOne(query, update)    update first matching document
+  db.<collection>.updateMany(query, update)   update all matching documents
+  db.<collection>.deleteOne(query)            delete first matching document
+  db.<collection>.deleteMany(query)           delete all matching documents
+  db.<collection>.drop()                      drop collection
+  show collections                            list collections
+  save [path]                                 write snapshot file
+  load path                                   read snapshot file
+  timing on|off                               print time taken by statements
+  exit                                        leave shell
+`
+
+// collectionMethods - methods completed after db.<collection>.
+var collectionMethods = []string{
+	"count(", "deleteMany(", "deleteOne(", "distinct(", "drop(", "find(", "findOne(",
+	"insertMany(", "insertOne(", "updateMany(", "updateOne(",
+}
+
+// commands - statements completed at start of line
+var commands = []string{"db.", "exit", "help", "load ", "save", "show collections", "timing "}
+
+// Shell - REPL evaluating statements against db
+type Shell struct {
+	db *memj.MemJ
+
+	// SnapshotPath - snapshot file used by save without path, set by load
+	SnapshotPath string
+	// Timing - print time taken by each statement
+	Timing bool
+	// HistoryFile - file lines are read from and appended to, empty disables
+	// persistent history
+	HistoryFile string
+}
+
+// New - create shell for db
+func New(db *memj.MemJ) *Shell {
+	return &Shell{db: db, Timing: true}
+}
+
+// Execute - evaluate statement and write its output to w
+func (s *Shell) Execute(w io.Writer, line string) error {
+	line = strings.TrimSuffix(strings.TrimSpace(line), ";")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	switch fields[0] {
+	case "exit", "quit":
+		return errExit
+
+	case "help":
+		fmt.Fprint(w, help)
+		return nil
+
+	case "show":
+		if len(fields) != 2 || fields[1] != "collections" {
+			return errors.New("Usage: show collections")
+		}
+		for _, name := range s.db.ListCollections() {
+			fmt.Fprintln(w, name)
+		}
+		return nil
+
+	case "save":
+		path := s.SnapshotPath
+		if len(fields) > 1 {
+			path = strings.TrimSpace(line[len("save"):])
+		}
+		if path == "" {
+			return errors.New("Usage: save path")
+		}
+		err := s.db.SaveFile(path)
+		if err != nil {
+			return err
+		}
+		s.SnapshotPath = path
+		fmt.Fprintln(w, "Saved", path)
+		return nil
+
+	case "load":
+		if len(fields) < 2 {
+			return errors.New("Usage: load path")
+		}
+		path := strings.TrimSpace(line[len("load"):])
+		err := s.db.LoadFile(path)
+		if err != nil {
+			return err
+		}
+		s.SnapshotPath = path
+		fmt.Fprintln(w, "Loaded", path)
+		return nil
+
+	case "timing":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			return errors.New("Usage: timing on|off")
+		}
+		s.Timing = fields[1] == "on"
+		return nil
+	}
+
+	if strings.HasPrefix(line, "db.") {
+		stmt, err := parseStatement(line)
+		if err != nil {
+			return err
+		}
+		return s.executeStatement(w, stmt)
+	}
+
+	return fmt.Errorf("Unknown statement %q, type help for list of statements", fields[0])
+}
+
+// statement - parsed db.<collection>.<method>(<args>).limit(n)
+type statement struct {
+	collection string
+	method     string
+	args       []interface{}
+	limit      int
+}
+
+func parseStatement(line string) (*statement, error) {
+	open := strings.IndexByte(line, '(')
+	if open < 0 {
+		return nil, errors.New("Expected db.<collection>.<method>(...)")
+	}
+
+	target := line[len("db."):open]
+	dot := strings.LastIndexByte(target, '.')
+	if dot <= 0 || dot == len(target)-1 {
+		return nil, errors.New("Expected db.<collection>.<method>(...)")
+	}
+	stmt := &statement{collection: target[:dot], method: target[dot+1:]}
+
+	end, err := matchingParen(line, open)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt.args, err = parseArgs(line[open+1 : end])
+	if err != nil {
+		return nil, err
+	}
+
+	rest := strings.TrimSpace(line[end+1:])
+	if rest != "" {
+		if !strings.HasPrefix(rest, ".limit(") || !strings.HasSuffix(rest, ")") {
+			return nil, fmt.Errorf("Unexpected %q after %s(...)", rest, stmt.method)
+		}
+		stmt.limit, err = strconv.Atoi(strings.TrimSpace(rest[len(".limit(") : len(rest)-1]))
+		if err != nil || stmt.limit < 0 {
+			return nil, errors.New("limit must be a non negative integer")
+		}
+	}
+
+	return stmt, nil
+}
+
+// matchingParen - index of parenthesis closing the one at open, brackets and
+// JSON strings are skipped
+func matchingParen(line string, open int) (int, error) {
+	depth := 0
+	inString := false
+	for i := open; i < len(line); i++ {
+		c := line[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+
+		case '(', '[', '{':
+			depth++
+
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if c != ')' {
+					return 0, errors.New("Unbalanced brackets")
+				}
+				return i, nil
+			}
+		}
+	}
+
+	return 0, errors.New("Missing closing parenthesis")
+}
+
+// splitArgs - split text at commas outside of brackets and strings
+func splitArgs(text string) []string {
+	var args []string
+	depth := 0
+	inString := false
+	start := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+
+		case '(', '[', '{':
+			depth++
+
+		case ')', ']', '}':
+			depth--
+
+		case ',':
+			if depth == 0 {
+				args = append(args, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if strings.TrimSpace(text[start:]) != "" || len(args) > 0 {
+		args = append(args, text[start:])
+	}
+	return args
+}
+
+// parseArgs - decode comma separated JSON values with ParseJSON conversions
+func parseArgs(text string) ([]interface{}, error) {
+	var args []interface{}
+	for i, arg := range splitArgs(text) {
+		arg = strings.TrimSpace(arg)
+		if !json.Valid([]byte(arg)) {
+			return nil, fmt.Errorf("Argument %d is not valid JSON", i+1)
+		}
+
+		wrapped, err := memj.ParseJSON(`{"value":` + arg + `}`)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %d: %v", i+1, err)
+		}
+		args = append(args, wrapped["value"])
+	}
+
+	return args, nil
+}
+
+func (s *Shell) executeStatement(w io.Writer, stmt *statement) error {
+	if stmt.limit > 0 && stmt.method != "find" {
+		return errors.New("limit is only supported after find")
+	}
+
+	switch stmt.method {
+	case "find":
+		query, err := stmt.query(0)
+		if err != nil {
+			return err
+		}
+		limit := stmt.limit
+		if len(stmt.args) > 1 {
+			n, ok := stmt.args[1].(int64)
+			if !ok || n < 0 {
+				return errors.New("limit must be a non negative integer")
+			}
+			limit = int(n)
+		}
+		documents, err := s.db.Query(stmt.collection, query, limit)
+		if err != nil {
+			return err
+		}
+		err = printDocuments(w, documents)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, len(documents), "documents")
+		return nil
+
+	case "findOne":
+		query, err := stmt.query(0)
+		if err != nil {
+			return err
+		}
+		documents, err := s.db.Query(stmt.collection, query, memj.FindOne)
+		if err != nil {
+			return err
+		}
+		if len(documents) == 0 {
+			fmt.Fprintln(w, "null")
+			return nil
+		}
+		return printDocuments(w, documents)
+
+	case "count":
+		query, err := stmt.query(0)
+		if err != nil {
+			return err
+		}
+		count, err := s.db.Count(stmt.collection, query)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, count)
+		return nil
+
+	case "distinct":
+		path, ok := stmt.arg(0).(string)
+		if !ok {
+			return errors.New("distinct expects field path as first argument")
+		}
+		var query map[string]interface{}
+		if len(stmt.args) > 1 {
+			var err error
+			query, err = stmt.query(1)
+			if err != nil {
+				return err
+			}
+		}
+		values, err := s.db.Distinct(stmt.collection, path, query)
+		if err != nil {
+			return err
+		}
+		return printValue(w, values)
+
+	case "insertOne":
+		document, ok := stmt.arg(0).(map[string]interface{})
+		if !ok || len(stmt.args) != 1 {
+			return errors.New("insertOne expects one document")
+		}
+		objectID, err := s.db.Insert(stmt.collection, document)
+		if err != nil {
+			return err
+		}
+		return printValue(w, map[string]interface{}{"insertedId": objectID})
+
+	case "insertMany":
+		list, ok := stmt.arg(0).([]interface{})
+		if !ok || len(stmt.args) != 1 {
+			return errors.New("insertMany expects array of documents")
+		}
+		for i, item := range list {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return fmt.Errorf("insertMany element %d is not a document", i)
+			}
+		}
+		ids := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			objectID, err := s.db.Insert(stmt.collection, item.(map[string]interface{}))
+			if err != nil {
+				printValue(w, map[string]interface{}{"insertedIds": ids})
+				return err
+			}
+			ids = append(ids, objectID)
+		}
+		return printValue(w, map[string]interface{}{"insertedIds": ids})
+
+	case "updateOne", "updateMany":
+		query, err := stmt.query(0)
+		if err != nil {
+			return err
+		}
+		fields, err := updateFields(stmt.arg(1))
+		if err != nil {
+			return err
+		}
+		limit := memj.NoLimit
+		if stmt.method == "updateOne" {
+			limit = memj.FindOne
+		}
+		documents, _, err := s.db.QueryAndUpdate(stmt.collection, query, fields, limit)
+		if err != nil {
+			return err
+		}
+		return printValue(w, map[string]interface{}{"modifiedCount": len(documents)})
+
+	case "deleteOne", "deleteMany":
+		if len(stmt.args) == 0 {
+			return fmt.Errorf("%s expects query, use {} to select all documents", stmt.method)
+		}
+		query, err := stmt.query(0)
+		if err != nil {
+			return err
+		}
+		limit := memj.NoLimit
+		if stmt.method == "deleteOne" {
+			limit = memj.FindOne
+		}
+		documents, err := s.db.Query(stmt.collection, query, limit)
+		if err != nil {
+			return err
+		}
+		deleted := 0
+		for _, document := range documents {
+			objectID, _ := document[s.db.PrimaryKey()].(string)
+			isDeleted, err := s.db.Delete(stmt.collection, objectID)
+			if err != nil {
+				return err
+			}
+			if isDeleted {
+				deleted++
+			}
+		}
+		return printValue(w, map[string]interface{}{"deletedCount": deleted})
+
+	case "drop":
+		dropped, err := s.db.DropCollection(stmt.collection)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, dropped)
+		return nil
+	}
+
+	return fmt.Errorf("Unknown method %s, type help for list of statements", stmt.method)
+}
+
+// arg - argument at index, nil when missing
+func (stmt *statement) arg(index int) interface{} {
+	if index >= len(stmt.args) {
+		return nil
+	}
+	return stmt.args[index]
+}
+
+// query - query argument at index, missing or empty query selects all documents
+func (stmt *statement) query(index int) (map[string]interface{}, error) {
+	value := stmt.arg(index)
+	if value == nil {
+		return memj.MatchAll(), nil
+	}
+
+	query, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s expects query document as argument %d", stmt.method, index+1)
+	}
+	if len(query) == 0 {
+		return memj.MatchAll(), nil
+	}
+
+	return query, nil
+}
+
+// updateFields - fields to set from {"$set": {...}} or plain document
+func updateFields(value interface{}) (map[string]interface{}, error) {
+	update, ok := value.(map[string]interface{})
+	if !ok || len(update) == 0 {
+		return nil, errors.New("Expected update document as second argument")
+	}
+
+	if fields, ok := update["$set"].(map[string]interface{}); ok && len(update) == 1 {
+		return fields, nil
+	}
+
+	for key := range update {
+		if strings.HasPrefix(key, "$") {
+			return nil, errors.New("Only $set updates are supported")
+		}
+	}
+
+	return update, nil
+}
+
+func printDocuments(w io.Writer, documents []map[string]interface{}) error {
+	for _, document := range documents {
+		err := printValue(w, document)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printValue - write value as indented extended JSON
+func printValue(w io.Writer, value interface{}) error {
+	encoded, err := memj.MarshalExtendedJSON(value)
+	if err != nil {
+		return err
+	}
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, encoded, "", "  ")
+	if err != nil {
+		return err
+	}
+	indented.WriteByte('\n')
+
+	_, err = w.Write(indented.Bytes())
+	return err
+}
+
+// fieldPaths - sorted dotted paths of fields in sample of collection documents
+func (s *Shell) fieldPaths(collection string) []string {
+	documents, err := s.db.Query(collection, memj.MatchAll(), sampleSize)
+	if err != nil {
+		return nil
+	}
+
+	paths := make(map[string]bool)
+	for _, document := range documents {
+		collectPaths("", document, paths)
+	}
+
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func collectPaths(prefix string, document map[string]interface{}, paths map[string]bool) {
+	for key, value := range document {
+		path := prefix + key
+		paths[path] = true
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			collectPaths(path+".", nested, paths)
+		}
+	}
+}
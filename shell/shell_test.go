@@ -0,0 +1,212 @@
+package shell
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/robjsliwa/memj"
+)
+
+func execute(t *testing.T, sh *Shell, line string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := sh.Execute(&out, line)
+	return out.String(), err
+}
+
+func newShell(t *testing.T) *Shell {
+	t.Helper()
+
+	db, _ := memj.New()
+	sh := New(db)
+	for _, line := range []string{
+		`db.users.insertOne({"name": "Ann", "age": 25, "address": {"city": "Oslo"}})`,
+		`db.users.insertMany([{"name": "Bob", "age": 35}, {"name": "Cid", "age": 45, "address": {"zip": "0150"}}])`,
+	} {
+		_, err := execute(t, sh, line)
+		if err != nil {
+			t.Fatal("Error inserting documents: ", err)
+		}
+	}
+
+	return sh
+}
+
+func TestFind(t *testing.T) {
+	sh := newShell(t)
+
+	out, err := execute(t, sh, `db.users.find({"age": {"$gt": 30}})`)
+	if err != nil {
+		t.Error("Error in find: ", err)
+		return
+	}
+
+	if !strings.Contains(out, `"name": "Bob"`) || !strings.Contains(out, `"name": "Cid"`) ||
+		!strings.HasSuffix(out, "2 documents\n") {
+		t.Error("Incorrect find output: ", out)
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.find({}).limit(1);`)
+	if !strings.HasSuffix(out, "1 documents\n") {
+		t.Error("Limit not applied: ", out)
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.find()`)
+	if !strings.HasSuffix(out, "3 documents\n") {
+		t.Error("Find without query does not return all documents: ", out)
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.findOne({"address.city": "Oslo"})`)
+	if !strings.Contains(out, `"name": "Ann"`) {
+		t.Error("Incorrect findOne output: ", out)
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.count({"name": {"$in": ["Ann", "Bob"]}})`)
+	if out != "2\n" {
+		t.Error("Incorrect count: ", out)
+		return
+	}
+}
+
+func TestUpdateDelete(t *testing.T) {
+	sh := newShell(t)
+
+	out, err := execute(t, sh, `db.users.updateMany({"age": {"$gte": 35}}, {"$set": {"senior": true}})`)
+	if err != nil || !strings.Contains(out, `"modifiedCount": 2`) {
+		t.Error("Incorrect updateMany result: ", out, err)
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.count({"senior": true})`)
+	if out != "2\n" {
+		t.Error("Documents not updated: ", out)
+		return
+	}
+
+	_, err = execute(t, sh, `db.users.updateOne({}, {"$inc": {"age": 1}})`)
+	if err == nil {
+		t.Error("Expected error for unsupported update operator")
+		return
+	}
+
+	out, err = execute(t, sh, `db.users.deleteOne({"senior": true})`)
+	if err != nil || !strings.Contains(out, `"deletedCount": 1`) {
+		t.Error("Incorrect deleteOne result: ", out, err)
+		return
+	}
+
+	_, err = execute(t, sh, `db.users.deleteMany()`)
+	if err == nil {
+		t.Error("Expected error for deleteMany without query")
+		return
+	}
+
+	out, _ = execute(t, sh, `db.users.deleteMany({})`)
+	if !strings.Contains(out, `"deletedCount": 2`) {
+		t.Error("Incorrect deleteMany result: ", out)
+		return
+	}
+}
+
+func TestStatementErrors(t *testing.T) {
+	sh := newShell(t)
+
+	for _, line := range []string{
+		`db.users.find({"age": }`,
+		`db.users.find({"age": 1}`,
+		`db.users.frobnicate()`,
+		`db.users.insertOne(42)`,
+		`db.users.find().skip(1)`,
+		`select * from users`,
+	} {
+		_, err := execute(t, sh, line)
+		if err == nil {
+			t.Error("Expected error for ", line)
+			return
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	db, _ := memj.New()
+	sh := New(db)
+	sh.Timing = false
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	script := strings.Join([]string{
+		`db.orders.insertOne({"price": 10})`,
+		`db.orders.insertOne({"price": "bad"`,
+		`save ` + path,
+		`show collections`,
+		`exit`,
+		`db.orders.drop()`,
+	}, "\n")
+
+	var out bytes.Buffer
+	err := sh.Run(strings.NewReader(script), &out)
+	if err != nil {
+		t.Error("Error running script: ", err)
+		return
+	}
+
+	if !strings.Contains(out.String(), "Error: Missing closing parenthesis") {
+		t.Error("Error not reported: ", out.String())
+		return
+	}
+
+	if !strings.HasSuffix(out.String(), "orders\n") {
+		t.Error("Script not stopped at exit: ", out.String())
+		return
+	}
+
+	restored, _ := memj.New()
+	err = restored.LoadFile(path)
+	if err != nil {
+		t.Error("Snapshot not saved: ", err)
+		return
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh := newShell(t)
+	sh.db.Insert("orders", map[string]interface{}{"price": 1})
+
+	start, candidates := sh.Complete("db.u", 4)
+	if start != 3 || len(candidates) != 1 || candidates[0] != "users" {
+		t.Error("Incorrect collection completion: ", start, candidates)
+		return
+	}
+
+	start, candidates = sh.Complete("db.users.fi", 11)
+	if start != 9 || strings.Join(candidates, ",") != "find(,findOne(" {
+		t.Error("Incorrect method completion: ", start, candidates)
+		return
+	}
+
+	line := `db.users.find({"address.`
+	start, candidates = sh.Complete(line, len(line))
+	if start != 16 || strings.Join(candidates, ",") != "address.city,address.zip" {
+		t.Error("Incorrect field path completion: ", start, candidates)
+		return
+	}
+
+	line = `db.users.find({"name": "a`
+	_, candidates = sh.Complete(line, len(line))
+	if len(candidates) != 0 {
+		t.Error("Field paths completed in value: ", candidates)
+		return
+	}
+
+	_, candidates = sh.Complete("sh", 2)
+	if len(candidates) != 1 || candidates[0] != "show collections" {
+		t.Error("Incorrect command completion: ", candidates)
+		return
+	}
+}
@@ -0,0 +1,204 @@
+package memj
+
+import (
+	"context"
+	"sort"
+)
+
+// Snapshot - repeatable view of all collections at the time it was taken
+//
+// Reads of snapshot return the same results however collections change
+// afterwards, documents expired at that time are left out.  Taking snapshot
+// does not block writers and writers are not slowed down by snapshots they
+// do not share documents with.  Versions of documents held by snapshot are
+// garbage collected after it is released.
+type Snapshot struct {
+	m           *MemJ
+	collections map[string]*collection
+}
+
+// Snapshot - take snapshot of all collections
+func (m *MemJ) Snapshot() (*Snapshot, error) {
+	return m.SnapshotCtx(context.Background())
+}
+
+// SnapshotCtx - like Snapshot but takes context
+func (m *MemJ) SnapshotCtx(ctx context.Context) (*Snapshot, error) {
+	m.mutexLock.RLock()
+	names := make(map[string]bool, len(m.collections))
+	for name := range m.collections {
+		names[name] = true
+	}
+	m.mutexLock.RUnlock()
+
+	versions, err := m.readCollections(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{m: m, collections: versions}, nil
+}
+
+// Release - release documents held by snapshot, snapshot must not be used
+// afterwards
+func (s *Snapshot) Release() {
+	s.collections = nil
+}
+
+// collection - version of collection in snapshot, empty when it did not
+// exist
+func (s *Snapshot) collection(name string) *collection {
+	if c, ok := s.collections[name]; ok {
+		return c
+	}
+	return &collection{primaryKey: s.m.primaryKey}
+}
+
+// versions - versions of named collections in snapshot
+func (s *Snapshot) versions(names map[string]bool) map[string]*collection {
+	versions := make(map[string]*collection, len(names))
+	for name := range names {
+		versions[name] = s.collection(name)
+	}
+	return versions
+}
+
+// ListCollections - return sorted names of collections in snapshot
+func (s *Snapshot) ListCollections() []string {
+	names := make([]string, 0, len(s.collections))
+	for name, c := range s.collections {
+		if c.exists() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Find - find document with primary key in collection of snapshot
+func (s *Snapshot) Find(collection, objectID string) (map[string]interface{}, error) {
+	return s.FindCtx(context.Background(), collection, objectID)
+}
+
+// FindCtx - like Find but takes context
+func (s *Snapshot) FindCtx(ctx context.Context, collection, objectID string) (map[string]interface{}, error) {
+	value, err := s.m.findVersion(ctx, s.collection(collection), objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.m.readDocument(value), nil
+}
+
+// FindAll - return all documents in collection of snapshot
+func (s *Snapshot) FindAll(collection string) ([]map[string]interface{}, error) {
+	return s.FindAllCtx(context.Background(), collection)
+}
+
+// FindAllCtx - like FindAll but takes context
+func (s *Snapshot) FindAllCtx(ctx context.Context, collection string) ([]map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.m.readDocuments(s.collection(collection).all()), nil
+}
+
+// Query - query documents in collection of snapshot, see MemJ.Query
+func (s *Snapshot) Query(collection string, query map[string]interface{}, limit int) ([]map[string]interface{}, error) {
+	return s.QueryCtx(context.Background(), collection, query, limit)
+}
+
+// QueryCtx - like Query but takes context
+func (s *Snapshot) QueryCtx(ctx context.Context, collection string, query map[string]interface{}, limit int) ([]map[string]interface{}, error) {
+	prepared, err := s.m.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.m.matchVersion(ctx, s.collection(collection), prepared, limit)
+	return s.m.readDocuments(results), err
+}
+
+// Count - count documents in collection of snapshot matching query, nil
+// query counts all documents
+func (s *Snapshot) Count(collection string, query map[string]interface{}) (int, error) {
+	return s.CountCtx(context.Background(), collection, query)
+}
+
+// CountCtx - like Count but takes context
+func (s *Snapshot) CountCtx(ctx context.Context, collection string, query map[string]interface{}) (int, error) {
+	prepared, err := s.m.compileFilter(query)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.m.countVersion(ctx, s.collection(collection), prepared)
+}
+
+// Aggregate - run aggregation pipeline over collection of snapshot, see
+// MemJ.Aggregate
+func (s *Snapshot) Aggregate(collection string, pipeline []interface{}) ([]map[string]interface{}, error) {
+	return s.AggregateCtx(context.Background(), collection, pipeline)
+}
+
+// AggregateCtx - like Aggregate but takes context
+func (s *Snapshot) AggregateCtx(ctx context.Context, collection string, pipeline []interface{}) ([]map[string]interface{}, error) {
+	names := map[string]bool{collection: true}
+	err := s.m.pipelineCollections(pipeline, names)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := s.versions(names)
+	results, err := s.m.runPipeline(ctx, versions, versions[collection].all(), pipeline)
+	return s.m.readDocuments(results), err
+}
+
+// readCollection - version of collection, see readCollections
+func (m *MemJ) readCollection(ctx context.Context, name string) (*collection, error) {
+	versions, err := m.readCollections(ctx, map[string]bool{name: true})
+	if err != nil {
+		return nil, err
+	}
+	return versions[name], nil
+}
+
+// readCollections - versions of collections for reading without holding
+// their locks
+//
+// Collections are read locked together only while versions are taken, so
+// versions are from the same point in time and long scans of them do not
+// block writers.  A version is unregistered collection sharing documents
+// with its collection, expired documents are left out.  Readers use all, or
+// the shards of sharded version.  Writers replace
+// documents and copy the documents slice instead of changing them once a
+// version shares them.
+func (m *MemJ) readCollections(ctx context.Context, names map[string]bool) (map[string]*collection, error) {
+	locked, unlock, err := m.rLockCollections(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	versions := make(map[string]*collection, len(locked))
+	for name, c := range locked {
+		version := &collection{
+			primaryKey: c.primaryKey,
+			validator:  c.validator,
+			ttl:        c.ttl,
+		}
+		version.created.Store(c.exists())
+
+		if c.shards == nil {
+			c.published.Store(true)
+			version.documents, version.sequences = m.live(c, &c.store)
+		} else {
+			version.shards = m.readShards(c)
+		}
+		versions[name] = version
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,133 @@
+package memj
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	memj, _ := New()
+	if !seedOrders(t, memj) {
+		return
+	}
+	memj.Insert("Customers", map[string]interface{}{"objectid": "c1", "Name": "c1"})
+
+	snapshot, err := memj.Snapshot()
+	if err != nil {
+		t.Error("Error taking snapshot: ", err)
+		return
+	}
+	defer snapshot.Release()
+
+	memj.Update("Orders", "1", map[string]interface{}{"Customer.Name": "changed"})
+	memj.Delete("Orders", "2")
+	memj.Insert("Orders", map[string]interface{}{"objectid": "3"})
+	memj.DropCollection("Customers")
+	memj.Insert("Items", map[string]interface{}{"Name": "item"})
+
+	if customerName(memj, "1") != "changed" {
+		t.Error("Expected update of collection")
+		return
+	}
+
+	document, err := snapshot.Find("Orders", "1")
+	if err != nil || document["Customer"].(map[string]interface{})["Name"] != "c1" {
+		t.Error("Expected document as of snapshot, got ", document, err)
+		return
+	}
+	if count, _ := snapshot.Count("Orders", nil); count != 3 {
+		t.Error("Expected 3 documents in snapshot, got ", count)
+		return
+	}
+	if _, err := snapshot.Find("Orders", "3"); err != ErrNotFound {
+		t.Error("Expected later insert to be missing from snapshot, got ", err)
+		return
+	}
+	if names := snapshot.ListCollections(); len(names) != 2 || names[0] != "Customers" || names[1] != "Orders" {
+		t.Error("Expected collections as of snapshot, got ", names)
+		return
+	}
+
+	results, err := snapshot.Aggregate("Orders", []interface{}{
+		map[string]interface{}{MATCH: map[string]interface{}{"Customer.Name": "c1"}},
+		map[string]interface{}{LOOKUP: map[string]interface{}{
+			"from": "Customers", "localField": "Customer.Name", "foreignField": "Name", "as": "Customers",
+		}},
+	})
+	if err != nil || len(results) != 1 || len(results[0]["Customers"].([]interface{})) != 1 {
+		t.Error("Expected lookup in dropped collection of snapshot, got ", results, err)
+		return
+	}
+
+	documents, _ := snapshot.Query("Orders", map[string]interface{}{"objectid": "2"}, NoLimit)
+	if len(documents) != 1 {
+		t.Error("Expected deleted document in snapshot, got ", documents)
+		return
+	}
+}
+
+func TestSnapshotTTL(t *testing.T) {
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	memj, _ := New(WithClock(clock))
+	defer memj.Close()
+
+	memj.SetTTL("Sessions", &TTL{Field: "At", ExpireAfter: time.Hour})
+	memj.Insert("Sessions", map[string]interface{}{"At": clock.Now()})
+
+	snapshot, _ := memj.Snapshot()
+	clock.Advance(2 * time.Hour)
+
+	if count, _ := memj.Count("Sessions", nil); count != 0 {
+		t.Error("Expected document to expire, got ", count)
+		return
+	}
+	if count, _ := snapshot.Count("Sessions", nil); count != 1 {
+		t.Error("Expected document alive at snapshot time, got ", count)
+		return
+	}
+}
+
+func TestReadsDoNotBlockWriters(t *testing.T) {
+	memj, _ := New()
+	insertOrders(t, memj, 1000)
+
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			memj.Query("TestCollection", map[string]interface{}{"OrderPrice": map[string]interface{}{GTE: 0}}, NoLimit)
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		objectID, err := memj.Insert("TestCollection", map[string]interface{}{"OrderPrice": float64(i)})
+		if err != nil {
+			t.Error("Error inserting: ", err)
+			break
+		}
+		if _, err := memj.Update("TestCollection", objectID, map[string]interface{}{"OrderPrice": float64(-i)}); err != nil {
+			t.Error("Error updating: ", err)
+			break
+		}
+		if i%2 == 0 {
+			if _, err := memj.Delete("TestCollection", objectID); err != nil {
+				t.Error("Error deleting: ", err)
+				break
+			}
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	if count, _ := memj.Count("TestCollection", nil); count != 1100 {
+		t.Error("Expected 1100 documents, got ", count)
+		return
+	}
+}
@@ -0,0 +1,220 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultReaperInterval - how often expired documents are removed unless
+// changed with WithReaperInterval
+const DefaultReaperInterval = time.Minute
+
+// TTL - expiry of documents by time valued field, like MongoDB TTL index
+type TTL struct {
+	// Field - dotted path of time.Time field, documents without it or with
+	// value of other type never expire
+	Field string
+	// ExpireAfter - documents expire this long after time in Field, zero
+	// expires them at that time
+	ExpireAfter time.Duration
+}
+
+// ttlIndex - TTL of collection with split field path
+type ttlIndex struct {
+	field       []string
+	expireAfter time.Duration
+}
+
+func newTTLIndex(ttl *TTL) (*ttlIndex, error) {
+	if ttl == nil {
+		return nil, nil
+	}
+	if ttl.Field == "" || strings.HasPrefix(ttl.Field, "$") {
+		return nil, errors.New("TTL field must be a field path")
+	}
+	if ttl.ExpireAfter < 0 {
+		return nil, errors.New("TTL expire after must not be negative")
+	}
+
+	return &ttlIndex{field: strings.Split(ttl.Field, "."), expireAfter: ttl.ExpireAfter}, nil
+}
+
+// SetTTL - expire documents of collection by ttl, nil ttl stops expiry
+//
+// Expired documents are never returned by reads.  They are removed from the
+// collection by the next write to it and by the reaper goroutine running
+// every reaper interval until Close.
+func (m *MemJ) SetTTL(collection string, ttl *TTL) error {
+	return m.SetTTLCtx(context.Background(), collection, ttl)
+}
+
+// SetTTLCtx - like SetTTL but takes context
+func (m *MemJ) SetTTLCtx(ctx context.Context, collection string, ttl *TTL) error {
+	index, err := newTTLIndex(ttl)
+	if err != nil {
+		return err
+	}
+
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if index != nil && c.capped != nil {
+		return errors.New("Capped collection cannot have TTL")
+	}
+
+	c.ttl = index
+	if index != nil {
+		m.removeExpired(c)
+		m.startReaper()
+	}
+
+	return nil
+}
+
+// RemoveExpired - remove expired documents from all collections, returns
+// number of removed documents
+//
+// The reaper goroutine calls it periodically, tests can call it to free
+// expired documents at a known point.
+func (m *MemJ) RemoveExpired() int {
+	m.mutexLock.RLock()
+	collections := make([]*collection, 0, len(m.collections))
+	for _, c := range m.collections {
+		collections = append(collections, c)
+	}
+	m.mutexLock.RUnlock()
+
+	removed := 0
+	for _, c := range collections {
+		c.lock.Lock()
+		if !c.dropped {
+			removed += m.removeExpired(c)
+		}
+		c.lock.Unlock()
+	}
+
+	return removed
+}
+
+// startReaper - start reaper goroutine unless it runs or MemJ is closed
+func (m *MemJ) startReaper() {
+	m.reaperLock.Lock()
+	defer m.reaperLock.Unlock()
+
+	if m.reaperStop != nil || m.closed {
+		return
+	}
+
+	m.reaperStop = make(chan struct{})
+	m.reaperDone = make(chan struct{})
+	go m.reap(m.reaperStop, m.reaperDone)
+}
+
+// stopReaper - stop reaper goroutine and wait for it to end, reaper is not
+// started again
+func (m *MemJ) stopReaper() {
+	m.reaperLock.Lock()
+	defer m.reaperLock.Unlock()
+
+	m.closed = true
+	if m.reaperStop == nil {
+		return
+	}
+
+	close(m.reaperStop)
+	<-m.reaperDone
+	m.reaperStop = nil
+}
+
+func (m *MemJ) reap(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.reaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			m.RemoveExpired()
+		}
+	}
+}
+
+// expired - document has expired at now
+func (m *MemJ) expired(c *collection, document map[string]interface{}, now time.Time) bool {
+	if c.ttl == nil {
+		return false
+	}
+
+	value, _ := m.lookupNestedValue(c.ttl.field, document)
+	t, ok := value.(time.Time)
+
+	return ok && !now.Before(t.Add(c.ttl.expireAfter))
+}
+
+// live - documents of store not expired with their sequence numbers, those
+// of store itself when none has expired, caller holds store lock
+func (m *MemJ) live(c *collection, s *store) ([]map[string]interface{}, []uint64) {
+	if c.ttl == nil {
+		return s.documents, s.sequences
+	}
+
+	now := m.now()
+	for i, document := range s.documents {
+		if !m.expired(c, document, now) {
+			continue
+		}
+
+		documents := make([]map[string]interface{}, i, len(s.documents)-1)
+		copy(documents, s.documents[:i])
+		sequences := make([]uint64, i, len(s.documents)-1)
+		copy(sequences, s.sequences[:i])
+		for j, document := range s.documents[i+1:] {
+			if !m.expired(c, document, now) {
+				documents = append(documents, document)
+				sequences = append(sequences, s.sequences[i+1+j])
+			}
+		}
+		return documents, sequences
+	}
+
+	return s.documents, s.sequences
+}
+
+// removeExpired - remove expired documents, caller holds collection write
+// lock
+func (m *MemJ) removeExpired(c *collection) int {
+	removed := 0
+	for _, s := range c.stores() {
+		removed += m.removeExpiredStore(c, s)
+	}
+
+	return removed
+}
+
+// removeExpiredStore - remove expired documents of store of collection,
+// caller holds store write lock
+func (m *MemJ) removeExpiredStore(c *collection, s *store) int {
+	documents, sequences := m.live(c, s)
+	if len(documents) == len(s.documents) {
+		return 0
+	}
+	removed := len(s.documents) - len(documents)
+
+	ids := make(map[string]bool, len(documents))
+	for _, document := range documents {
+		objectID, _ := document[c.primaryKey].(string)
+		ids[objectID] = true
+	}
+	s.documents, s.sequences, s.ids = documents, sequences, ids
+
+	return removed
+}
@@ -0,0 +1,208 @@
+package memj
+
+import (
+	"testing"
+	"time"
+)
+
+func insertSessions(t *testing.T, memj *MemJ, start time.Time) bool {
+	for i := 0; i < 5; i++ {
+		_, err := memj.Insert("Sessions", map[string]interface{}{
+			"User":      i,
+			"CreatedAt": start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestTTL(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	memj, _ := New(WithClock(clock))
+	defer memj.Close()
+
+	err := memj.CreateCollection("Sessions", CollectionOptions{TTL: &TTL{Field: "CreatedAt", ExpireAfter: time.Hour}})
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+	if !insertSessions(t, memj, start) {
+		return
+	}
+	memj.Insert("Sessions", map[string]interface{}{"User": "no expiry"})
+
+	// sessions created at 0 and 1 minute have expired
+	clock.Advance(time.Hour + time.Minute)
+
+	if count, _ := memj.Count("Sessions", nil); count != 4 {
+		t.Error("Expired documents counted: ", count)
+		return
+	}
+
+	documents, _ := memj.Query("Sessions", map[string]interface{}{"User": map[string]interface{}{LT: 2}}, NoLimit)
+	if len(documents) != 0 {
+		t.Error("Expired documents returned by Query: ", documents)
+		return
+	}
+
+	all, _ := memj.FindAll("Sessions")
+	if len(all) != 4 {
+		t.Error("Expired documents returned by FindAll: ", len(all))
+		return
+	}
+
+	cursor, _ := memj.QueryCursor("Sessions", map[string]interface{}{"User": map[string]interface{}{GTE: 0}}, CursorOptions{BatchSize: 1})
+	clock.Advance(time.Minute)
+	returned := 0
+	for cursor.Next() {
+		returned++
+	}
+	if returned != 2 {
+		t.Error("Document expired after cursor was opened returned: ", returned)
+		return
+	}
+
+	// reads do not remove expired documents, writes do
+	c := memj.collections["Sessions"]
+	if len(c.documents) != 6 {
+		t.Error("Expired documents removed by read: ", len(c.documents))
+		return
+	}
+
+	memj.Insert("Sessions", map[string]interface{}{"User": "new"})
+	if len(c.documents) != 4 {
+		t.Error("Expired documents not removed by write: ", len(c.documents))
+		return
+	}
+}
+
+func TestTTLExpireAtFieldTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	memj, _ := New(WithClock(clock))
+	defer memj.Close()
+
+	objectID, _ := memj.Insert("Cache", map[string]interface{}{"Value": 1, "Expires": map[string]interface{}{"At": start.Add(time.Second)}})
+	memj.SetTTL("Cache", &TTL{Field: "Expires.At"})
+
+	if _, err := memj.Find("Cache", objectID); err != nil {
+		t.Error("Document expired early: ", err)
+		return
+	}
+
+	clock.Advance(time.Second)
+	if _, err := memj.Find("Cache", objectID); err != ErrNotFound {
+		t.Error("Expired document found: ", err)
+		return
+	}
+
+	if removed := memj.RemoveExpired(); removed != 1 {
+		t.Error("Incorrect number of removed documents: ", removed)
+		return
+	}
+
+	// expired objectid can be used again
+	if _, err := memj.Insert("Cache", map[string]interface{}{memj.PrimaryKey(): objectID}); err != nil {
+		t.Error("Error reusing objectid of expired document: ", err)
+		return
+	}
+
+	// removing TTL stops expiry
+	memj.SetTTL("Cache", nil)
+	memj.Insert("Cache", map[string]interface{}{"Value": 2, "Expires": map[string]interface{}{"At": start}})
+	if count, _ := memj.Count("Cache", nil); count != 2 {
+		t.Error("Documents expired without TTL: ", count)
+		return
+	}
+}
+
+func TestTTLLookup(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	memj, _ := New(WithClock(clock))
+	defer memj.Close()
+
+	memj.Insert("Users", map[string]interface{}{"User": 1})
+	memj.CreateCollection("Sessions", CollectionOptions{TTL: &TTL{Field: "CreatedAt", ExpireAfter: time.Minute}})
+	insertSessions(t, memj, start.Add(-4*time.Minute))
+
+	results, err := memj.Aggregate("Users", []interface{}{
+		map[string]interface{}{LOOKUP: map[string]interface{}{
+			"from": "Sessions", "localField": "User", "foreignField": "User", "as": "Sessions",
+		}},
+	})
+	if err != nil {
+		t.Error("Error running aggregation: ", err)
+		return
+	}
+
+	if len(results) != 1 || len(results[0]["Sessions"].([]interface{})) != 0 {
+		t.Error("Expired document joined: ", results)
+		return
+	}
+}
+
+func TestTTLReaper(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	memj, _ := New(WithClock(clock), WithReaperInterval(time.Millisecond))
+
+	memj.SetTTL("Sessions", &TTL{Field: "CreatedAt", ExpireAfter: time.Hour})
+	insertSessions(t, memj, start)
+	clock.Advance(2 * time.Hour)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c := memj.collections["Sessions"]
+		c.lock.RLock()
+		remaining := len(c.documents)
+		c.lock.RUnlock()
+
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Error("Expired documents not removed by reaper: ", remaining)
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	memj.Close()
+	if memj.reaperStop != nil {
+		t.Error("Reaper not stopped by Close")
+		return
+	}
+
+	// reaper is not started again after Close
+	memj.SetTTL("Orders", &TTL{Field: "CreatedAt"})
+	if memj.reaperStop != nil {
+		t.Error("Reaper started after Close")
+		return
+	}
+}
+
+func TestInvalidTTL(t *testing.T) {
+	memj, _ := New()
+
+	for _, ttl := range []*TTL{{}, {Field: "$date"}, {Field: "CreatedAt", ExpireAfter: -time.Second}} {
+		if err := memj.SetTTL("Sessions", ttl); err == nil {
+			t.Errorf("Expected error for TTL %+v", ttl)
+			return
+		}
+		if err := memj.CreateCollection("Sessions", CollectionOptions{TTL: ttl}); err == nil {
+			t.Errorf("Expected error creating collection with TTL %+v", ttl)
+			return
+		}
+	}
+
+	if _, err := New(WithReaperInterval(0)); err == nil {
+		t.Error("Expected error for zero reaper interval")
+		return
+	}
+}
@@ -0,0 +1,264 @@
+package memj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Collection - typed handle over collection of MemJ storing values of struct type T
+//
+// Values are converted to and from documents using their json encoding, so
+// json struct tags control field names.  The string field tagged `memj:"id"`,
+// or the field encoded as the primary key of db, holds the primary key of the
+// document.  time.Time fields are stored as time.Time values, so they can be
+// used by TTL and compared in queries.
+type Collection[T any] struct {
+	db      *MemJ
+	name    string
+	idIndex []int
+	idName  string
+}
+
+// NewCollection - create typed handle for collection in db
+func NewCollection[T any](db *MemJ, name string) (*Collection[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("Collection type must be a struct")
+	}
+
+	c := &Collection[T]{db: db, name: name}
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		jsonName, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+
+		if field.Tag.Get("memj") == "id" || (c.idIndex == nil && jsonName == db.PrimaryKey()) {
+			if field.Type.Kind() != reflect.String {
+				return nil, errors.New("Collection id field must be a string")
+			}
+			c.idIndex = field.Index
+			c.idName = jsonName
+		}
+	}
+
+	return c, nil
+}
+
+// Insert - insert value and set its id field to the new primary key
+//
+// Value with non-empty id field is stored under that primary key.
+func (c *Collection[T]) Insert(value *T) (string, error) {
+	return c.InsertCtx(context.Background(), value)
+}
+
+// InsertCtx - like Insert but takes context
+func (c *Collection[T]) InsertCtx(ctx context.Context, value *T) (string, error) {
+	payload, err := c.encode(*value)
+	if err != nil {
+		return "", err
+	}
+
+	if c.idIndex != nil {
+		if id := reflect.ValueOf(value).Elem().FieldByIndex(c.idIndex).String(); id != "" {
+			payload[c.db.PrimaryKey()] = id
+		}
+	}
+
+	objectID, err := c.db.InsertCtx(ctx, c.name, payload)
+	if err != nil {
+		return "", err
+	}
+
+	if c.idIndex != nil {
+		reflect.ValueOf(value).Elem().FieldByIndex(c.idIndex).SetString(objectID)
+	}
+
+	return objectID, nil
+}
+
+// Find - find value with objectID
+func (c *Collection[T]) Find(objectID string) (T, error) {
+	return c.FindCtx(context.Background(), objectID)
+}
+
+// FindCtx - like Find but takes context
+func (c *Collection[T]) FindCtx(ctx context.Context, objectID string) (T, error) {
+	document, err := c.db.FindCtx(ctx, c.name, objectID)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+
+	return c.decode(document)
+}
+
+// FindAll - return all values in collection
+func (c *Collection[T]) FindAll() ([]T, error) {
+	return c.FindAllCtx(context.Background())
+}
+
+// FindAllCtx - like FindAll but takes context
+func (c *Collection[T]) FindAllCtx(ctx context.Context) ([]T, error) {
+	documents, err := c.db.FindAllCtx(ctx, c.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.decodeAll(documents)
+}
+
+// Query - query for values in collection
+func (c *Collection[T]) Query(query map[string]interface{}, limit int) ([]T, error) {
+	return c.QueryCtx(context.Background(), query, limit)
+}
+
+// QueryCtx - like Query but takes context
+func (c *Collection[T]) QueryCtx(ctx context.Context, query map[string]interface{}, limit int) ([]T, error) {
+	documents, err := c.db.QueryCtx(ctx, c.name, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.decodeAll(documents)
+}
+
+// Update - replace fields of document identified by objectID with fields of value
+func (c *Collection[T]) Update(objectID string, value T) (bool, error) {
+	return c.UpdateCtx(context.Background(), objectID, value)
+}
+
+// UpdateCtx - like Update but takes context
+func (c *Collection[T]) UpdateCtx(ctx context.Context, objectID string, value T) (bool, error) {
+	payload, err := c.encode(value)
+	if err != nil {
+		return false, err
+	}
+
+	return c.db.UpdateCtx(ctx, c.name, objectID, payload)
+}
+
+// Delete - delete value identified by objectID
+func (c *Collection[T]) Delete(objectID string) (bool, error) {
+	return c.DeleteCtx(context.Background(), objectID)
+}
+
+// DeleteCtx - like Delete but takes context
+func (c *Collection[T]) DeleteCtx(ctx context.Context, objectID string) (bool, error) {
+	return c.db.DeleteCtx(ctx, c.name, objectID)
+}
+
+func (c *Collection[T]) encode(value T) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := ParseJSON(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	restoreTimes(reflect.ValueOf(value), payload)
+
+	if c.idIndex != nil {
+		delete(payload, c.idName)
+	}
+	delete(payload, c.db.PrimaryKey())
+
+	return payload, nil
+}
+
+// jsonFieldName - name of struct field in its json encoding, false for fields
+// skipped by json
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name, true
+	}
+
+	tagName := strings.Split(tag, ",")[0]
+	if tagName == "-" {
+		return "", false
+	}
+	if tagName == "" {
+		return field.Name, true
+	}
+	return tagName, true
+}
+
+// restoreTimes - replace time strings in payload encoded from struct value
+// with time.Time values of its fields, including fields of nested structs
+func restoreTimes(value reflect.Value, payload map[string]interface{}) {
+	for _, field := range reflect.VisibleFields(value.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		jsonName, ok := jsonFieldName(field)
+		if !ok {
+			continue
+		}
+
+		fieldValue, err := value.FieldByIndexErr(field.Index)
+		if err != nil {
+			continue
+		}
+		for fieldValue.Kind() == reflect.Pointer && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+
+		switch encoded := payload[jsonName].(type) {
+		case string:
+			if t, ok := fieldValue.Interface().(time.Time); ok {
+				payload[jsonName] = t
+			}
+
+		case map[string]interface{}:
+			if fieldValue.Kind() == reflect.Struct {
+				restoreTimes(fieldValue, encoded)
+			}
+		}
+	}
+}
+
+func (c *Collection[T]) decode(document map[string]interface{}) (T, error) {
+	var value T
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return value, err
+	}
+
+	err = json.Unmarshal(encoded, &value)
+	if err != nil {
+		return value, err
+	}
+
+	if c.idIndex != nil {
+		objectID, _ := document[c.db.PrimaryKey()].(string)
+		reflect.ValueOf(&value).Elem().FieldByIndex(c.idIndex).SetString(objectID)
+	}
+
+	return value, nil
+}
+
+func (c *Collection[T]) decodeAll(documents []map[string]interface{}) ([]T, error) {
+	values := make([]T, 0, len(documents))
+	for _, document := range documents {
+		value, err := c.decode(document)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
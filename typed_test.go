@@ -0,0 +1,269 @@
+package memj
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testOrder struct {
+	ID       string `memj:"id"`
+	Name     string `json:"name"`
+	Price    float64
+	Customer struct {
+		Region string
+	}
+}
+
+func TestCollectionInsertAndFind(t *testing.T) {
+	memj, _ := New()
+	orders, err := NewCollection[testOrder](memj, "Orders")
+
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	order := testOrder{Name: "Platypus", Price: 12.5}
+	order.Customer.Region = "EU"
+	objectID, err := orders.Insert(&order)
+
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	if objectID == "" || order.ID != objectID {
+		t.Error("Invalid objectID")
+		return
+	}
+
+	found, err := orders.Find(objectID)
+
+	if err != nil {
+		t.Error("Error in Find: ", err)
+		return
+	}
+
+	if found != order {
+		t.Error("Wrong value returned")
+		return
+	}
+
+	document, _ := memj.Find("Orders", objectID)
+	if document["name"] != "Platypus" {
+		t.Error("json tag not used as field name")
+		return
+	}
+
+	if _, ok := document["ID"]; ok {
+		t.Error("id field stored in document")
+		return
+	}
+}
+
+func TestCollectionInsertWithID(t *testing.T) {
+	memj, _ := New()
+	orders, _ := NewCollection[testOrder](memj, "Orders")
+
+	order := testOrder{ID: "order-1", Name: "Platypus"}
+	objectID, err := orders.Insert(&order)
+	if err != nil || objectID != "order-1" || order.ID != "order-1" {
+		t.Error("Id field not used as objectid: ", objectID, err)
+		return
+	}
+
+	if _, err := orders.Insert(&order); err != ErrDuplicateID {
+		t.Error("Expected ErrDuplicateID, got ", err)
+		return
+	}
+}
+
+func TestCollectionPrimaryKey(t *testing.T) {
+	type user struct {
+		ID   string `json:"_id"`
+		Name string `json:"name"`
+	}
+
+	memj, _ := New()
+	memj.SetPrimaryKey("_id")
+	users, _ := NewCollection[user](memj, "Users")
+
+	value := user{Name: "Rob"}
+	objectID, err := users.Insert(&value)
+	if err != nil || value.ID != objectID {
+		t.Error("Id field not set from primary key: ", err)
+		return
+	}
+
+	found, err := users.Find(objectID)
+	if err != nil || found != value {
+		t.Error("Wrong value returned: ", found, err)
+		return
+	}
+
+	if _, err := users.Update(objectID, user{Name: "Ann"}); err != nil {
+		t.Error("Error updating value: ", err)
+		return
+	}
+}
+
+func TestCollectionQueryAndUpdate(t *testing.T) {
+	memj, _ := New()
+	orders, err := NewCollection[testOrder](memj, "Orders")
+
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		order := testOrder{Name: fmt.Sprintf("Order-%d", i), Price: float64(i)}
+		_, err = orders.Insert(&order)
+
+		if err != nil {
+			t.Error("Error inserting document: ", err)
+			return
+		}
+	}
+
+	var jsonQuery = []byte(`{"Price": {"$gte": 5}}`)
+	var queryPayload map[string]interface{}
+	err = json.Unmarshal(jsonQuery, &queryPayload)
+
+	if err != nil {
+		t.Error("Error unmarshalling: ", err)
+		return
+	}
+
+	results, err := orders.Query(queryPayload, NoLimit)
+
+	if err != nil {
+		t.Error("Error in Query: ", err)
+		return
+	}
+
+	if len(results) != 5 || results[0].Name != "Order-5" {
+		t.Error("Incorrect values returned")
+		return
+	}
+
+	updated := results[0]
+	updated.Name = "Changed"
+	isUpdated, err := orders.Update(updated.ID, updated)
+
+	if err != nil || !isUpdated {
+		t.Error("Error in Update: ", err)
+		return
+	}
+
+	found, err := orders.Find(updated.ID)
+
+	if err != nil {
+		t.Error("Error in Find: ", err)
+		return
+	}
+
+	if found != updated {
+		t.Error("Value not updated")
+		return
+	}
+}
+
+func TestCollectionObjectIDJSONTag(t *testing.T) {
+	type user struct {
+		ObjectID string `json:"objectid"`
+		Name     string
+	}
+
+	memj, _ := New()
+	users, err := NewCollection[user](memj, "Users")
+
+	if err != nil {
+		t.Error("Error creating collection: ", err)
+		return
+	}
+
+	value := user{Name: "Ann"}
+	objectID, err := users.Insert(&value)
+
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	all, err := users.FindAll()
+
+	if err != nil {
+		t.Error("Error in FindAll: ", err)
+		return
+	}
+
+	if len(all) != 1 || all[0].ObjectID != objectID {
+		t.Error("objectid not mapped to field")
+		return
+	}
+}
+
+func TestCollectionTimeFields(t *testing.T) {
+	type session struct {
+		ID        string `memj:"id"`
+		CreatedAt time.Time
+		Login     struct {
+			At *time.Time
+		}
+	}
+
+	clock := NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	memj, _ := New(WithClock(clock))
+	defer memj.Close()
+	memj.SetTTL("Sessions", &TTL{Field: "CreatedAt", ExpireAfter: time.Hour})
+	sessions, _ := NewCollection[session](memj, "Sessions")
+
+	value := session{CreatedAt: clock.Now()}
+	value.Login.At = &value.CreatedAt
+	objectID, err := sessions.Insert(&value)
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	document, _ := memj.Find("Sessions", objectID)
+	if _, ok := document["Login"].(map[string]interface{})["At"].(time.Time); !ok {
+		t.Error("Expected nested time stored as time.Time, got ", document)
+		return
+	}
+
+	found, err := sessions.Query(map[string]interface{}{"CreatedAt": map[string]interface{}{LT: clock.Now().Add(time.Minute)}}, NoLimit)
+	if err != nil || len(found) != 1 || !found[0].CreatedAt.Equal(value.CreatedAt) {
+		t.Error("Expected session found by time, got ", found, err)
+		return
+	}
+
+	clock.Advance(2 * time.Hour)
+	if count, _ := memj.Count("Sessions", nil); count != 0 {
+		t.Error("Expected expired session to be removed, got ", count)
+		return
+	}
+}
+
+func TestCollectionInvalidType(t *testing.T) {
+	memj, _ := New()
+	_, err := NewCollection[string](memj, "Orders")
+
+	if err == nil {
+		t.Error("Non struct type but no error")
+		return
+	}
+
+	type badID struct {
+		ID int `memj:"id"`
+	}
+	_, err = NewCollection[badID](memj, "Orders")
+
+	if err == nil {
+		t.Error("Non string id field but no error")
+		return
+	}
+}
@@ -0,0 +1,416 @@
+package memj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationLevel - which writes are validated
+type ValidationLevel int
+
+// Validation level constants
+const (
+	// ValidationStrict - validate all inserts and updates
+	ValidationStrict ValidationLevel = iota
+	// ValidationModerate - validate inserts and updates of documents that
+	// already satisfy the schema
+	ValidationModerate
+)
+
+// ValidationAction - what happens to documents that fail validation
+type ValidationAction int
+
+// Validation action constants
+const (
+	// ValidationError - reject write with DocumentValidationError
+	ValidationError ValidationAction = iota
+	// ValidationWarn - log failure and accept write
+	ValidationWarn
+)
+
+// ValidatorOptions - options for SetValidator
+type ValidatorOptions struct {
+	Level  ValidationLevel
+	Action ValidationAction
+}
+
+// DocumentValidationError - document does not satisfy collection schema
+type DocumentValidationError struct {
+	Collection string
+	Errors     []string
+}
+
+func (e *DocumentValidationError) Error() string {
+	return "Document failed validation: " + strings.Join(e.Errors, "; ")
+}
+
+type validator struct {
+	schema  *schema
+	options ValidatorOptions
+}
+
+type schema struct {
+	types                []string
+	required             []string
+	properties           map[string]*schema
+	additionalProperties *schema
+	noAdditional         bool
+	enum                 []interface{}
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	minLength, maxLength *float64
+	minItems, maxItems   *float64
+	items                *schema
+}
+
+// SetValidator - validate documents written to collection against JSON Schema
+//
+// The schema supports a subset of draft 2020-12: type, required, properties,
+// additionalProperties, enum, pattern, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, minLength, maxLength, minItems, maxItems and items.  Other
+// keywords are ignored.  The primary key field is always allowed.  A nil schema
+// removes the validator.
+func (m *MemJ) SetValidator(collection string, jsonSchema map[string]interface{}, options ValidatorOptions) error {
+	return m.SetValidatorCtx(context.Background(), collection, jsonSchema, options)
+}
+
+// SetValidatorCtx - like SetValidator but takes context
+func (m *MemJ) SetValidatorCtx(ctx context.Context, collection string, jsonSchema map[string]interface{}, options ValidatorOptions) error {
+	v, err := newValidator(jsonSchema, options)
+	if err != nil {
+		return err
+	}
+
+	c, unlock, err := m.lockCollection(ctx, collection)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c.validator = v
+
+	return nil
+}
+
+// newValidator - compile jsonSchema, nil schema gives nil validator
+func newValidator(jsonSchema map[string]interface{}, options ValidatorOptions) (*validator, error) {
+	if jsonSchema == nil {
+		return nil, nil
+	}
+
+	s, err := compileSchema(jsonSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &validator{schema: s, options: options}, nil
+}
+
+// validateInsert - check document inserted to collection, nil when it may be stored
+func (m *MemJ) validateInsert(c *collection, name string, document map[string]interface{}) error {
+	v := c.validator
+	if v == nil {
+		return nil
+	}
+
+	return m.checkDocument(v, name, document)
+}
+
+// validateUpdate - check document before and after update
+func (m *MemJ) validateUpdate(c *collection, name string, document, updated map[string]interface{}) error {
+	v := c.validator
+	if v == nil {
+		return nil
+	}
+
+	if v.options.Level == ValidationModerate && len(v.schema.validate(m, document, "", true)) > 0 {
+		return nil
+	}
+
+	return m.checkDocument(v, name, updated)
+}
+
+func (m *MemJ) checkDocument(v *validator, collection string, document map[string]interface{}) error {
+	errs := v.schema.validate(m, document, "", true)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	err := &DocumentValidationError{Collection: collection, Errors: errs}
+	if v.options.Action == ValidationWarn {
+		m.logger.Printf("memj: collection %s: %v", collection, err)
+		return nil
+	}
+
+	return err
+}
+
+func compileSchema(jsonSchema map[string]interface{}) (*schema, error) {
+	s := &schema{}
+
+	for k, v := range jsonSchema {
+		var err error
+		switch k {
+		case "type":
+			s.types, err = schemaStrings(k, v)
+			for _, t := range s.types {
+				switch t {
+				case "object", "array", "string", "number", "integer", "boolean", "null":
+				default:
+					err = fmt.Errorf("Unsupported schema type %q", t)
+				}
+			}
+
+		case "required":
+			s.required, err = schemaStrings(k, v)
+
+		case "properties":
+			properties, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("Schema properties must be an object")
+			}
+			s.properties = make(map[string]*schema, len(properties))
+			for name, property := range properties {
+				s.properties[name], err = compileSubSchema(name, property)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case "additionalProperties":
+			if allowed, ok := v.(bool); ok {
+				s.noAdditional = !allowed
+			} else {
+				s.additionalProperties, err = compileSubSchema(k, v)
+			}
+
+		case "items":
+			s.items, err = compileSubSchema(k, v)
+
+		case "enum":
+			var ok bool
+			s.enum, ok = v.([]interface{})
+			if !ok {
+				err = errors.New("Schema enum must be a list")
+			}
+
+		case "pattern":
+			pattern, ok := v.(string)
+			if !ok {
+				return nil, errors.New("Schema pattern must be a string")
+			}
+			s.pattern, err = regexp.Compile(pattern)
+
+		case "minimum":
+			s.minimum, err = schemaNumber(k, v)
+		case "maximum":
+			s.maximum, err = schemaNumber(k, v)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = schemaNumber(k, v)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = schemaNumber(k, v)
+		case "minLength":
+			s.minLength, err = schemaNumber(k, v)
+		case "maxLength":
+			s.maxLength, err = schemaNumber(k, v)
+		case "minItems":
+			s.minItems, err = schemaNumber(k, v)
+		case "maxItems":
+			s.maxItems, err = schemaNumber(k, v)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func compileSubSchema(name string, value interface{}) (*schema, error) {
+	subSchema, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Schema for %s must be an object", name)
+	}
+
+	return compileSchema(subSchema)
+}
+
+func schemaStrings(keyword string, value interface{}) ([]string, error) {
+	if str, ok := value.(string); ok {
+		return []string{str}, nil
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Schema %s must be a string or list of strings", keyword)
+	}
+
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("Schema %s must be a string or list of strings", keyword)
+		}
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+func schemaNumber(keyword string, value interface{}) (*float64, error) {
+	_, number, _, ok := numberValue(value)
+	if !ok {
+		return nil, fmt.Errorf("Schema %s must be a number", keyword)
+	}
+
+	return &number, nil
+}
+
+// validate - list of errors for value at path, root allows primary key field
+func (s *schema) validate(m *MemJ, value interface{}, path string, root bool) []string {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		field := path
+		if field == "" {
+			field = "document"
+		}
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.types) > 0 && !s.matchesType(value) {
+		fail("must be of type %s", strings.Join(s.types, " or "))
+		return errs
+	}
+
+	if s.enum != nil {
+		inEnum := false
+		for _, e := range s.enum {
+			if m.valuesEqual(e, value) {
+				inEnum = true
+				break
+			}
+		}
+		if !inEnum {
+			fail("must be one of enum values")
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := value[name]; !ok {
+				fail("missing required field %s", name)
+			}
+		}
+
+		for name, fieldValue := range value {
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+
+			if property, ok := s.properties[name]; ok {
+				errs = append(errs, property.validate(m, fieldValue, fieldPath, false)...)
+			} else if root && name == m.primaryKey {
+				continue
+			} else if s.noAdditional {
+				fail("additional field %s is not allowed", name)
+			} else if s.additionalProperties != nil {
+				errs = append(errs, s.additionalProperties.validate(m, fieldValue, fieldPath, false)...)
+			}
+		}
+
+	case []interface{}:
+		count := float64(len(value))
+		if s.minItems != nil && count < *s.minItems {
+			fail("must have at least %v items", *s.minItems)
+		}
+		if s.maxItems != nil && count > *s.maxItems {
+			fail("must have at most %v items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range value {
+				errs = append(errs, s.items.validate(m, item, fmt.Sprintf("%s[%d]", path, i), false)...)
+			}
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(value))
+		if s.minLength != nil && length < *s.minLength {
+			fail("must be at least %v characters long", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			fail("must be at most %v characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			fail("must match pattern %s", s.pattern)
+		}
+
+	default:
+		_, number, _, isNumber := numberValue(value)
+		if !isNumber {
+			break
+		}
+		if s.minimum != nil && number < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && number > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && number <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && number >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+	}
+
+	return errs
+}
+
+func (s *schema) matchesType(value interface{}) bool {
+	for _, t := range s.types {
+		switch value.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+
+		case string:
+			if t == "string" {
+				return true
+			}
+
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+
+		case nil:
+			if t == "null" {
+				return true
+			}
+
+		default:
+			_, number, isInt, isNumber := numberValue(value)
+			if isNumber && (t == "number" || (t == "integer" && (isInt || number == math.Trunc(number)))) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,181 @@
+package memj
+
+import (
+	"errors"
+	"testing"
+)
+
+const testOrderSchema = `{
+	"type": "object",
+	"required": ["OrderID", "OrderPrice"],
+	"additionalProperties": false,
+	"properties": {
+		"OrderID": {"type": "string", "pattern": "^id-[0-9]+$"},
+		"OrderPrice": {"type": "number", "minimum": 0},
+		"Status": {"enum": ["new", "shipped"]},
+		"Tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "minLength": 1}},
+		"Customer": {"type": "object", "properties": {"Age": {"type": "integer", "exclusiveMaximum": 150}}}
+	}
+}`
+
+func setOrderValidator(t *testing.T, memj *MemJ, options ValidatorOptions) bool {
+	schema, err := ParseJSON(testOrderSchema)
+
+	if err != nil {
+		t.Error("Error parsing schema: ", err)
+		return false
+	}
+
+	err = memj.SetValidator("TestCollection", schema, options)
+
+	if err != nil {
+		t.Error("Error in SetValidator: ", err)
+		return false
+	}
+
+	return true
+}
+
+func TestValidatorInsert(t *testing.T) {
+	memj, _ := New()
+	if !setOrderValidator(t, memj, ValidatorOptions{}) {
+		return
+	}
+
+	_, err := memj.InsertJSON("TestCollection", `{"OrderID": "id-1", "OrderPrice": 10, "Tags": ["a"], "Customer": {"Age": 30}}`)
+
+	if err != nil {
+		t.Error("Error inserting valid document: ", err)
+		return
+	}
+
+	for _, payload := range []string{
+		`{"OrderID": "id-1"}`,
+		`{"OrderID": "order-1", "OrderPrice": 10}`,
+		`{"OrderID": "id-1", "OrderPrice": -1}`,
+		`{"OrderID": "id-1", "OrderPrice": 10, "Status": "lost"}`,
+		`{"OrderID": "id-1", "OrderPrice": 10, "Tags": ["a", "b", "c"]}`,
+		`{"OrderID": "id-1", "OrderPrice": 10, "Tags": [""]}`,
+		`{"OrderID": "id-1", "OrderPrice": 10, "Customer": {"Age": 30.5}}`,
+		`{"OrderID": "id-1", "OrderPrice": 10, "Extra": true}`,
+	} {
+		_, err = memj.InsertJSON("TestCollection", payload)
+
+		var validationErr *DocumentValidationError
+		if !errors.As(err, &validationErr) {
+			t.Error("Invalid document inserted: ", payload)
+			return
+		}
+	}
+
+	count, _ := memj.Count("TestCollection", nil)
+	if count != 1 {
+		t.Error("Invalid documents stored")
+		return
+	}
+}
+
+func TestValidatorUpdate(t *testing.T) {
+	memj, _ := New()
+	if !setOrderValidator(t, memj, ValidatorOptions{}) {
+		return
+	}
+
+	objectID, err := memj.InsertJSON("TestCollection", `{"OrderID": "id-1", "OrderPrice": 10}`)
+
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	_, err = memj.UpdateJSON("TestCollection", objectID, `{"OrderPrice": "free"}`)
+
+	if err == nil {
+		t.Error("Invalid update but no error")
+		return
+	}
+
+	document, _ := memj.Find("TestCollection", objectID)
+	if document["OrderPrice"] != int64(10) {
+		t.Error("Invalid update applied")
+		return
+	}
+
+	query, _ := ParseJSON(`{"OrderID": "id-1"}`)
+	update, _ := ParseJSON(`{"Status": "unknown"}`)
+	_, _, err = memj.QueryAndUpdate("TestCollection", query, update, NoLimit)
+
+	if err == nil {
+		t.Error("Invalid update but no error")
+		return
+	}
+}
+
+func TestValidatorModerateLevel(t *testing.T) {
+	memj, _ := New()
+
+	objectID, err := memj.InsertJSON("TestCollection", `{"OrderID": "legacy"}`)
+
+	if err != nil {
+		t.Error("Error inserting document: ", err)
+		return
+	}
+
+	if !setOrderValidator(t, memj, ValidatorOptions{Level: ValidationModerate}) {
+		return
+	}
+
+	_, err = memj.UpdateJSON("TestCollection", objectID, `{"Note": "still invalid"}`)
+
+	if err != nil {
+		t.Error("Update of invalid document rejected in moderate level: ", err)
+		return
+	}
+
+	_, err = memj.InsertJSON("TestCollection", `{"OrderID": "legacy"}`)
+
+	if err == nil {
+		t.Error("Invalid insert but no error")
+		return
+	}
+}
+
+func TestValidatorWarnAction(t *testing.T) {
+	memj, _ := New()
+	if !setOrderValidator(t, memj, ValidatorOptions{Action: ValidationWarn}) {
+		return
+	}
+
+	_, err := memj.InsertJSON("TestCollection", `{"OrderID": "id-1"}`)
+
+	if err != nil {
+		t.Error("Invalid document rejected with warn action: ", err)
+		return
+	}
+
+	err = memj.SetValidator("TestCollection", nil, ValidatorOptions{})
+
+	if err != nil {
+		t.Error("Error removing validator: ", err)
+		return
+	}
+}
+
+func TestValidatorInvalidSchema(t *testing.T) {
+	memj, _ := New()
+
+	for _, text := range []string{
+		`{"type": "decimal"}`,
+		`{"properties": {"Name": "string"}}`,
+		`{"pattern": "("}`,
+		`{"minimum": "0"}`,
+	} {
+		schema, _ := ParseJSON(text)
+		err := memj.SetValidator("TestCollection", schema, ValidatorOptions{})
+
+		if err == nil {
+			t.Error("Invalid schema but no error: ", text)
+			return
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// BSON element type constants
+const (
+	bsonDouble    = 0x01
+	bsonString    = 0x02
+	bsonDocument  = 0x03
+	bsonArray     = 0x04
+	bsonBinary    = 0x05
+	bsonObjectID  = 0x07
+	bsonBool      = 0x08
+	bsonDateTime  = 0x09
+	bsonNull      = 0x0A
+	bsonInt32     = 0x10
+	bsonTimestamp = 0x11
+	bsonInt64     = 0x12
+)
+
+// ObjectID - BSON ObjectId, stored in documents as is so it compares by value
+type ObjectID [12]byte
+
+// String - hex representation of id
+func (id ObjectID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalJSON - encode id as extended json
+func (id ObjectID) MarshalJSON() ([]byte, error) {
+	return []byte(`{"$oid":"` + id.String() + `"}`), nil
+}
+
+// Binary - BSON binary data with its subtype
+type Binary struct {
+	Subtype byte
+	Data    []byte
+}
+
+// Timestamp - BSON internal timestamp
+type Timestamp struct {
+	T uint32
+	I uint32
+}
+
+// Element - key and value of ordered document
+type Element struct {
+	Key   string
+	Value interface{}
+}
+
+// Document - BSON document preserving order of keys, commands depend on it
+type Document []Element
+
+// Lookup - value of key, nil when missing
+func (d Document) Lookup(key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// Map - convert document and all nested documents to maps
+func (d Document) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(d))
+	for _, e := range d {
+		m[e.Key] = toMapValue(e.Value)
+	}
+	return m
+}
+
+func toMapValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case Document:
+		return value.Map()
+
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, v := range value {
+			list[i] = toMapValue(v)
+		}
+		return list
+	}
+
+	return value
+}
+
+// decodeDocument - decode BSON document, returns bytes following it
+func decodeDocument(data []byte) (Document, []byte, error) {
+	if len(data) < 5 {
+		return nil, nil, errors.New("BSON document too short")
+	}
+
+	size := int(int32(binary.LittleEndian.Uint32(data)))
+	if size < 5 || size > len(data) || data[size-1] != 0 {
+		return nil, nil, errors.New("Invalid BSON document size")
+	}
+
+	body := data[4 : size-1]
+	doc := Document{}
+	for len(body) > 0 {
+		elemType := body[0]
+		key, rest, err := readCString(body[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var value interface{}
+		value, body, err = decodeValue(elemType, rest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", key, err)
+		}
+
+		doc = append(doc, Element{Key: key, Value: value})
+	}
+
+	return doc, data[size:], nil
+}
+
+func decodeValue(elemType byte, data []byte) (interface{}, []byte, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return errors.New("BSON value truncated")
+		}
+		return nil
+	}
+
+	switch elemType {
+	case bsonDouble:
+		if err := need(8); err != nil {
+			return nil, nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:], nil
+
+	case bsonString:
+		if err := need(4); err != nil {
+			return nil, nil, err
+		}
+		size := int(int32(binary.LittleEndian.Uint32(data)))
+		if size < 1 || len(data) < 4+size || data[3+size] != 0 {
+			return nil, nil, errors.New("Invalid BSON string")
+		}
+		return string(data[4 : 3+size]), data[4+size:], nil
+
+	case bsonDocument:
+		return decodeDocument(data)
+
+	case bsonArray:
+		doc, rest, err := decodeDocument(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		list := make([]interface{}, len(doc))
+		for i, e := range doc {
+			list[i] = e.Value
+		}
+		return list, rest, nil
+
+	case bsonBinary:
+		if err := need(5); err != nil {
+			return nil, nil, err
+		}
+		size := int(int32(binary.LittleEndian.Uint32(data)))
+		if size < 0 || len(data) < 5+size {
+			return nil, nil, errors.New("Invalid BSON binary")
+		}
+		bin := Binary{Subtype: data[4], Data: append([]byte(nil), data[5:5+size]...)}
+		return bin, data[5+size:], nil
+
+	case bsonObjectID:
+		if err := need(12); err != nil {
+			return nil, nil, err
+		}
+		var id ObjectID
+		copy(id[:], data)
+		return id, data[12:], nil
+
+	case bsonBool:
+		if err := need(1); err != nil {
+			return nil, nil, err
+		}
+		return data[0] != 0, data[1:], nil
+
+	case bsonDateTime:
+		if err := need(8); err != nil {
+			return nil, nil, err
+		}
+		millis := int64(binary.LittleEndian.Uint64(data))
+		return time.UnixMilli(millis).UTC(), data[8:], nil
+
+	case bsonNull:
+		return nil, data, nil
+
+	case bsonInt32:
+		if err := need(4); err != nil {
+			return nil, nil, err
+		}
+		return int32(binary.LittleEndian.Uint32(data)), data[4:], nil
+
+	case bsonTimestamp:
+		if err := need(8); err != nil {
+			return nil, nil, err
+		}
+		ts := Timestamp{I: binary.LittleEndian.Uint32(data), T: binary.LittleEndian.Uint32(data[4:])}
+		return ts, data[8:], nil
+
+	case bsonInt64:
+		if err := need(8); err != nil {
+			return nil, nil, err
+		}
+		return int64(binary.LittleEndian.Uint64(data)), data[8:], nil
+	}
+
+	return nil, nil, fmt.Errorf("Unsupported BSON type 0x%02x", elemType)
+}
+
+func readCString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, errors.New("Unterminated BSON string")
+	}
+	return string(data[:end]), data[end+1:], nil
+}
+
+// encodeDocument - encode Document or map as BSON, map keys are sorted with _id first
+func encodeDocument(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeDocument(&buf, value)
+	return buf.Bytes(), err
+}
+
+func writeDocument(buf *bytes.Buffer, value interface{}) error {
+	var doc Document
+	switch value := value.(type) {
+	case Document:
+		doc = value
+
+	case map[string]interface{}:
+		doc = sortedDocument(value)
+
+	default:
+		return fmt.Errorf("Cannot encode %T as BSON document", value)
+	}
+
+	start := buf.Len()
+	buf.Write([]byte{0, 0, 0, 0})
+	for _, e := range doc {
+		err := writeElement(buf, e.Key, e.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", e.Key, err)
+		}
+	}
+	buf.WriteByte(0)
+
+	binary.LittleEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start))
+	return nil
+}
+
+func sortedDocument(m map[string]interface{}) Document {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "_id" || keys[j] == "_id" {
+			return keys[i] == "_id"
+		}
+		return keys[i] < keys[j]
+	})
+
+	doc := make(Document, 0, len(keys))
+	for _, k := range keys {
+		doc = append(doc, Element{Key: k, Value: m[k]})
+	}
+	return doc
+}
+
+func writeElement(buf *bytes.Buffer, key string, value interface{}) error {
+	writeHeader := func(elemType byte) {
+		buf.WriteByte(elemType)
+		buf.WriteString(key)
+		buf.WriteByte(0)
+	}
+	var scratch [8]byte
+
+	switch value := value.(type) {
+	case nil:
+		writeHeader(bsonNull)
+
+	case float64:
+		writeHeader(bsonDouble)
+		binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(value))
+		buf.Write(scratch[:8])
+
+	case float32:
+		return writeElement(buf, key, float64(value))
+
+	case string:
+		writeHeader(bsonString)
+		binary.LittleEndian.PutUint32(scratch[:], uint32(len(value)+1))
+		buf.Write(scratch[:4])
+		buf.WriteString(value)
+		buf.WriteByte(0)
+
+	case Document, map[string]interface{}:
+		writeHeader(bsonDocument)
+		return writeDocument(buf, value)
+
+	case []interface{}:
+		writeHeader(bsonArray)
+		array := make(Document, len(value))
+		for i, v := range value {
+			array[i] = Element{Key: fmt.Sprint(i), Value: v}
+		}
+		return writeDocument(buf, array)
+
+	case []map[string]interface{}:
+		list := make([]interface{}, len(value))
+		for i, v := range value {
+			list[i] = v
+		}
+		return writeElement(buf, key, list)
+
+	case Binary:
+		writeHeader(bsonBinary)
+		binary.LittleEndian.PutUint32(scratch[:], uint32(len(value.Data)))
+		buf.Write(scratch[:4])
+		buf.WriteByte(value.Subtype)
+		buf.Write(value.Data)
+
+	case ObjectID:
+		writeHeader(bsonObjectID)
+		buf.Write(value[:])
+
+	case bool:
+		writeHeader(bsonBool)
+		if value {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+
+	case time.Time:
+		writeHeader(bsonDateTime)
+		binary.LittleEndian.PutUint64(scratch[:], uint64(value.UnixMilli()))
+		buf.Write(scratch[:8])
+
+	case int32:
+		writeHeader(bsonInt32)
+		binary.LittleEndian.PutUint32(scratch[:], uint32(value))
+		buf.Write(scratch[:4])
+
+	case Timestamp:
+		writeHeader(bsonTimestamp)
+		binary.LittleEndian.PutUint32(scratch[:], value.I)
+		binary.LittleEndian.PutUint32(scratch[4:], value.T)
+		buf.Write(scratch[:8])
+
+	case int64:
+		writeHeader(bsonInt64)
+		binary.LittleEndian.PutUint64(scratch[:], uint64(value))
+		buf.Write(scratch[:8])
+
+	default:
+		v := reflect.ValueOf(value)
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return writeElement(buf, key, v.Int())
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.Uint() > math.MaxInt64 {
+				return writeElement(buf, key, float64(v.Uint()))
+			}
+			return writeElement(buf, key, int64(v.Uint()))
+		}
+
+		return fmt.Errorf("Cannot encode %T as BSON", value)
+	}
+
+	return nil
+}
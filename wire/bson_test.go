@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBSONRoundTrip(t *testing.T) {
+	id := newObjectID()
+	doc := Document{
+		{Key: "_id", Value: id},
+		{Key: "double", Value: 1.5},
+		{Key: "string", Value: "text"},
+		{Key: "nested", Value: Document{{Key: "a", Value: int32(1)}}},
+		{Key: "array", Value: []interface{}{int64(1), "two", nil}},
+		{Key: "binary", Value: Binary{Subtype: 4, Data: []byte{1, 2, 3}}},
+		{Key: "bool", Value: true},
+		{Key: "date", Value: time.UnixMilli(1600000000123).UTC()},
+		{Key: "null", Value: nil},
+		{Key: "timestamp", Value: Timestamp{T: 7, I: 9}},
+	}
+
+	encoded, err := encodeDocument(doc)
+
+	if err != nil {
+		t.Error("Error encoding document: ", err)
+		return
+	}
+
+	decoded, rest, err := decodeDocument(encoded)
+
+	if err != nil {
+		t.Error("Error decoding document: ", err)
+		return
+	}
+
+	if len(rest) != 0 {
+		t.Error("Unexpected trailing bytes")
+		return
+	}
+
+	if !reflect.DeepEqual(doc, decoded) {
+		t.Error("Decoded document differs: ", decoded)
+		return
+	}
+}
+
+func TestBSONMapKeysOrdered(t *testing.T) {
+	encoded, err := encodeDocument(map[string]interface{}{"b": 1, "_id": "x", "a": 2.5})
+
+	if err != nil {
+		t.Error("Error encoding document: ", err)
+		return
+	}
+
+	decoded, _, err := decodeDocument(encoded)
+
+	if err != nil {
+		t.Error("Error decoding document: ", err)
+		return
+	}
+
+	if decoded[0].Key != "_id" || decoded[1].Key != "a" || decoded[2].Value != int64(1) {
+		t.Error("Unexpected document: ", decoded)
+		return
+	}
+}
+
+func TestBSONInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{1, 0, 0},
+		{10, 0, 0, 0, 0},
+		{12, 0, 0, 0, 0x02, 'a', 0, 9, 0, 0, 0, 0},
+		{8, 0, 0, 0, 0x13, 'a', 0, 0},
+	} {
+		_, _, err := decodeDocument(data)
+		if err == nil {
+			t.Error("Invalid BSON but no error: ", data)
+			return
+		}
+	}
+}
@@ -0,0 +1,811 @@
+package wire
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robjsliwa/memj"
+)
+
+// Error codes returned to clients
+const (
+	codeBadValue                  = 2
+	codeFailedToParse             = 9
+	codeNamespaceNotFound         = 26
+	codeCursorNotFound            = 43
+	codeCommandNotFound           = 59
+	codeDocumentValidationFailure = 121
+	codeNotImplemented            = 238
+	codeDuplicateKey              = 11000
+)
+
+var codeNames = map[int32]string{
+	codeBadValue:                  "BadValue",
+	codeFailedToParse:             "FailedToParse",
+	codeNamespaceNotFound:         "NamespaceNotFound",
+	codeCursorNotFound:            "CursorNotFound",
+	codeCommandNotFound:           "CommandNotFound",
+	codeDocumentValidationFailure: "DocumentValidationFailure",
+	codeNotImplemented:            "NotImplemented",
+	codeDuplicateKey:              "DuplicateKey",
+}
+
+// defaultBatchSize - size of first batch when find does not specify one
+const defaultBatchSize = 101
+
+// cursorTimeout - cursors not used by getMore for this long are closed, like
+// the default cursorTimeoutMillis of MongoDB
+const cursorTimeout = 10 * time.Minute
+
+// commandError - error reported to client with code
+type commandError struct {
+	code    int32
+	message string
+}
+
+func (e *commandError) Error() string {
+	return e.message
+}
+
+func newError(code int32, format string, args ...interface{}) error {
+	return &commandError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// errorCode - code for store error, validation failures and bad queries are distinguished
+func errorCode(err error) int32 {
+	var cmdErr *commandError
+	var validationErr *memj.DocumentValidationError
+
+	switch {
+	case errors.As(err, &cmdErr):
+		return cmdErr.code
+
+	case errors.As(err, &validationErr):
+		return codeDocumentValidationFailure
+	}
+
+	return codeBadValue
+}
+
+func errorReply(err error) Document {
+	code := errorCode(err)
+	return Document{
+		{Key: "ok", Value: 0.0},
+		{Key: "errmsg", Value: err.Error()},
+		{Key: "code", Value: code},
+		{Key: "codeName", Value: codeNames[code]},
+	}
+}
+
+func (s *Server) handleCommand(connectionID int32, msg *message) Document {
+	if len(msg.command) == 0 {
+		return errorReply(newError(codeFailedToParse, "Empty command"))
+	}
+
+	var reply Document
+	var err error
+	name := msg.command[0].Key
+
+	switch name {
+	case "hello", "isMaster", "ismaster":
+		reply = s.hello(connectionID, name != "hello")
+
+	case "ping", "endSessions":
+		reply = Document{}
+
+	case "buildInfo", "buildinfo":
+		reply = Document{
+			{Key: "version", Value: "6.0.0"},
+			{Key: "versionArray", Value: []interface{}{int32(6), int32(0), int32(0), int32(0)}},
+		}
+
+	case "insert":
+		reply, err = s.insert(msg.command)
+
+	case "find":
+		reply, err = s.find(msg.database, msg.command)
+
+	case "getMore":
+		reply, err = s.getMore(msg.database, msg.command)
+
+	case "killCursors":
+		reply, err = s.killCursors(msg.command)
+
+	case "update":
+		reply, err = s.update(msg.command)
+
+	case "delete":
+		reply, err = s.delete(msg.command)
+
+	case "listCollections":
+		reply, err = s.listCollections(msg.database, msg.command)
+
+	case "drop":
+		reply, err = s.drop(msg.database, msg.command)
+
+	default:
+		err = newError(codeCommandNotFound, "no such command: '%s'", name)
+	}
+
+	if err != nil {
+		return errorReply(err)
+	}
+
+	return append(reply, Element{Key: "ok", Value: 1.0})
+}
+
+func (s *Server) hello(connectionID int32, legacy bool) Document {
+	reply := Document{
+		{Key: "isWritablePrimary", Value: true},
+		{Key: "helloOk", Value: true},
+		{Key: "maxBsonObjectSize", Value: int32(16 * 1024 * 1024)},
+		{Key: "maxMessageSizeBytes", Value: int32(maxMessageSize)},
+		{Key: "maxWriteBatchSize", Value: int32(100000)},
+		{Key: "localTime", Value: time.Now()},
+		{Key: "logicalSessionTimeoutMinutes", Value: int32(30)},
+		{Key: "connectionId", Value: connectionID},
+		{Key: "minWireVersion", Value: int32(0)},
+		{Key: "maxWireVersion", Value: int32(17)},
+		{Key: "readOnly", Value: false},
+	}
+
+	if legacy {
+		reply[0].Key = "ismaster"
+	}
+
+	return reply
+}
+
+func collectionName(command Document) (string, error) {
+	name, ok := command[0].Value.(string)
+	if !ok || name == "" {
+		return "", newError(codeFailedToParse, "collection name must be a string")
+	}
+	return name, nil
+}
+
+// toQuery - convert filter to memj query, empty filter matches all documents
+func toQuery(filter interface{}) (map[string]interface{}, error) {
+	switch filter := filter.(type) {
+	case nil:
+		return memj.MatchAll(), nil
+
+	case Document:
+		if len(filter) == 0 {
+			return memj.MatchAll(), nil
+		}
+		return filter.Map(), nil
+	}
+
+	return nil, newError(codeFailedToParse, "filter must be a document")
+}
+
+func documentList(command Document, key string) ([]Document, error) {
+	list, ok := command.Lookup(key).([]interface{})
+	if !ok {
+		return nil, newError(codeFailedToParse, "%s must be an array", key)
+	}
+
+	docs := make([]Document, 0, len(list))
+	for _, item := range list {
+		doc, ok := item.(Document)
+		if !ok {
+			return nil, newError(codeFailedToParse, "%s must contain documents", key)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+func intValue(value interface{}) (int64, bool) {
+	switch value := value.(type) {
+	case int32:
+		return int64(value), true
+
+	case int64:
+		return value, true
+
+	case float64:
+		if value == math.Trunc(value) {
+			return int64(value), true
+		}
+	}
+
+	return 0, false
+}
+
+func boolValue(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	n, ok := intValue(value)
+	return ok && n != 0
+}
+
+func writeError(index int, err error) Document {
+	return Document{
+		{Key: "index", Value: int32(index)},
+		{Key: "code", Value: errorCode(err)},
+		{Key: "errmsg", Value: err.Error()},
+	}
+}
+
+func (s *Server) insert(command Document) (Document, error) {
+	collection, err := collectionName(command)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := documentList(command, "documents")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := command.Lookup("ordered") == nil || boolValue(command.Lookup("ordered"))
+
+	n := 0
+	var writeErrors []interface{}
+	for i, doc := range docs {
+		payload := doc.Map()
+		if _, ok := payload["_id"]; !ok {
+			payload["_id"] = newObjectID()
+		}
+
+		_, err = s.insertDocument(collection, payload)
+
+		if err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			if ordered {
+				break
+			}
+			continue
+		}
+		n++
+	}
+
+	reply := Document{{Key: "n", Value: int32(n)}}
+	if writeErrors != nil {
+		reply = append(reply, Element{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+// insertDocument - insert payload unless collection has document with its _id
+func (s *Server) insertDocument(collection string, payload map[string]interface{}) (string, error) {
+	defer s.lockIDs(collection)()
+
+	exists, err := s.db.Exists(collection, map[string]interface{}{"_id": payload["_id"]})
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", newError(codeDuplicateKey, "E11000 duplicate key error collection: %s index: _id_", collection)
+	}
+
+	return s.db.Insert(collection, payload)
+}
+
+func (s *Server) find(database string, command Document) (Document, error) {
+	collection, err := collectionName(command)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := toQuery(command.Lookup("filter"))
+	if err != nil {
+		return nil, err
+	}
+
+	if sortSpec, ok := command.Lookup("sort").(Document); ok && len(sortSpec) > 0 {
+		return nil, newError(codeNotImplemented, "sort is not supported")
+	}
+
+	limit, _ := intValue(command.Lookup("limit"))
+	skip, _ := intValue(command.Lookup("skip"))
+	batchSize, hasBatchSize := intValue(command.Lookup("batchSize"))
+	singleBatch := boolValue(command.Lookup("singleBatch"))
+	if limit < 0 {
+		limit = -limit
+		singleBatch = true
+	}
+	if !hasBatchSize {
+		batchSize = defaultBatchSize
+	}
+	if skip < 0 || batchSize < 0 {
+		return nil, newError(codeBadValue, "skip and batchSize must not be negative")
+	}
+
+	if limit != 0 {
+		limit += skip
+	}
+	cursor, err := s.db.QueryCursor(collection, query, memj.CursorOptions{Limit: int(limit)})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := int64(0); i < skip && cursor.Next(); i++ {
+	}
+
+	c := &serverCursor{namespace: database + "." + collection, collection: collection, primaryKey: s.db.PrimaryKey(), cursor: cursor}
+	batch, err := c.nextBatch(batchSize)
+	if err != nil {
+		cursor.Close()
+		return nil, err
+	}
+
+	id := int64(0)
+	if !c.exhausted && !singleBatch {
+		id = s.cursors.add(c)
+	} else {
+		cursor.Close()
+	}
+
+	return cursorReply(id, c.namespace, "firstBatch", batch), nil
+}
+
+func (s *Server) getMore(database string, command Document) (Document, error) {
+	id, ok := command[0].Value.(int64)
+	if !ok {
+		return nil, newError(codeFailedToParse, "getMore cursor id must be a long")
+	}
+
+	collection, _ := command.Lookup("collection").(string)
+	c := s.cursors.get(id)
+	if c == nil || c.collection != collection {
+		return nil, newError(codeCursorNotFound, "cursor id %d not found", id)
+	}
+
+	batchSize, ok := intValue(command.Lookup("batchSize"))
+	if !ok || batchSize <= 0 {
+		batchSize = math.MaxInt32
+	}
+
+	c.mutex.Lock()
+	batch, err := c.nextBatch(batchSize)
+	c.mutex.Unlock()
+
+	if err != nil || c.exhausted {
+		s.cursors.remove(id)
+		if err != nil {
+			return nil, err
+		}
+		id = 0
+	}
+
+	return cursorReply(id, c.namespace, "nextBatch", batch), nil
+}
+
+func (s *Server) killCursors(command Document) (Document, error) {
+	ids, ok := command.Lookup("cursors").([]interface{})
+	if !ok {
+		return nil, newError(codeFailedToParse, "cursors must be an array")
+	}
+
+	var killed, notFound []interface{}
+	for _, value := range ids {
+		id, _ := value.(int64)
+		if s.cursors.remove(id) {
+			killed = append(killed, id)
+		} else {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return Document{
+		{Key: "cursorsKilled", Value: append([]interface{}{}, killed...)},
+		{Key: "cursorsNotFound", Value: append([]interface{}{}, notFound...)},
+		{Key: "cursorsAlive", Value: []interface{}{}},
+		{Key: "cursorsUnknown", Value: []interface{}{}},
+	}, nil
+}
+
+func (s *Server) update(command Document) (Document, error) {
+	collection, err := collectionName(command)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, err := documentList(command, "updates")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := command.Lookup("ordered") == nil || boolValue(command.Lookup("ordered"))
+
+	n, modified := 0, 0
+	var upserted, writeErrors []interface{}
+	for i, spec := range updates {
+		matched, upsertedID, err := s.updateOne(collection, spec)
+		if err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			if ordered {
+				break
+			}
+			continue
+		}
+
+		if upsertedID != nil {
+			upserted = append(upserted, Document{{Key: "index", Value: int32(i)}, {Key: "_id", Value: upsertedID}})
+			n++
+		} else {
+			n += matched
+			modified += matched
+		}
+	}
+
+	reply := Document{{Key: "n", Value: int32(n)}, {Key: "nModified", Value: int32(modified)}}
+	if upserted != nil {
+		reply = append(reply, Element{Key: "upserted", Value: upserted})
+	}
+	if writeErrors != nil {
+		reply = append(reply, Element{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+// updateOne - apply update statement, only $set updates are supported
+func (s *Server) updateOne(collection string, spec Document) (int, interface{}, error) {
+	query, err := toQuery(spec.Lookup("q"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	update, ok := spec.Lookup("u").(Document)
+	if !ok {
+		return 0, nil, newError(codeNotImplemented, "only update documents are supported")
+	}
+
+	fields := map[string]interface{}{}
+	for _, e := range update {
+		if e.Key != "$set" {
+			if strings.HasPrefix(e.Key, "$") {
+				return 0, nil, newError(codeNotImplemented, "update operator %s is not supported", e.Key)
+			}
+			return 0, nil, newError(codeNotImplemented, "replacement updates are not supported")
+		}
+
+		set, ok := e.Value.(Document)
+		if !ok {
+			return 0, nil, newError(codeFailedToParse, "$set must be a document")
+		}
+		for k, v := range set.Map() {
+			if k == "_id" {
+				return 0, nil, newError(codeBadValue, "_id cannot be modified")
+			}
+			fields[k] = v
+		}
+	}
+
+	limit := memj.FindOne
+	if boolValue(spec.Lookup("multi")) {
+		limit = memj.NoLimit
+	}
+
+	results, _, err := s.db.QueryAndUpdate(collection, query, fields, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(results) == 0 && boolValue(spec.Lookup("upsert")) {
+		return s.upsert(collection, spec.Lookup("q"), fields)
+	}
+
+	return len(results), nil, nil
+}
+
+// upsert - insert document built from equality fields of filter and $set fields
+func (s *Server) upsert(collection string, filter interface{}, fields map[string]interface{}) (int, interface{}, error) {
+	payload := map[string]interface{}{}
+	if filterDoc, ok := filter.(Document); ok {
+		for k, v := range filterDoc.Map() {
+			if strings.HasPrefix(k, "$") || strings.Contains(k, ".") {
+				continue
+			}
+			if expr, ok := v.(map[string]interface{}); ok && len(expr) > 0 {
+				continue
+			}
+			payload[k] = v
+		}
+	}
+
+	if _, ok := payload["_id"]; !ok {
+		payload["_id"] = newObjectID()
+	}
+
+	nested := map[string]interface{}{}
+	for k, v := range fields {
+		if strings.Contains(k, ".") {
+			nested[k] = v
+		} else {
+			payload[k] = v
+		}
+	}
+
+	objectID, err := s.insertDocument(collection, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(nested) > 0 {
+		_, err = s.db.Update(collection, objectID, nested)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return 0, payload["_id"], nil
+}
+
+func (s *Server) delete(command Document) (Document, error) {
+	collection, err := collectionName(command)
+	if err != nil {
+		return nil, err
+	}
+
+	deletes, err := documentList(command, "deletes")
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := command.Lookup("ordered") == nil || boolValue(command.Lookup("ordered"))
+
+	n := 0
+	var writeErrors []interface{}
+	for i, spec := range deletes {
+		query, err := toQuery(spec.Lookup("q"))
+		if err == nil {
+			limit, _ := intValue(spec.Lookup("limit"))
+			var documents []map[string]interface{}
+			documents, err = s.db.Query(collection, query, int(limit))
+			for _, document := range documents {
+				objectID, _ := document[s.db.PrimaryKey()].(string)
+				var isDeleted bool
+				isDeleted, err = s.db.Delete(collection, objectID)
+				if err == memj.ErrNotFound {
+					// deleted by another client since the query
+					err = nil
+				}
+				if err != nil {
+					break
+				}
+				if isDeleted {
+					n++
+				}
+			}
+		}
+
+		if err != nil {
+			writeErrors = append(writeErrors, writeError(i, err))
+			if ordered {
+				break
+			}
+		}
+	}
+
+	reply := Document{{Key: "n", Value: int32(n)}}
+	if writeErrors != nil {
+		reply = append(reply, Element{Key: "writeErrors", Value: writeErrors})
+	}
+
+	return reply, nil
+}
+
+func (s *Server) listCollections(database string, command Document) (Document, error) {
+	var nameFilter interface{}
+	if filter, ok := command.Lookup("filter").(Document); ok {
+		nameFilter = filter.Lookup("name")
+	}
+
+	batch := []interface{}{}
+	for _, name := range s.db.ListCollections() {
+		if nameFilter != nil && nameFilter != name {
+			continue
+		}
+		batch = append(batch, Document{
+			{Key: "name", Value: name},
+			{Key: "type", Value: "collection"},
+			{Key: "options", Value: Document{}},
+			{Key: "info", Value: Document{{Key: "readOnly", Value: false}}},
+		})
+	}
+
+	return Document{{Key: "cursor", Value: Document{
+		{Key: "id", Value: int64(0)},
+		{Key: "ns", Value: database + ".$cmd.listCollections"},
+		{Key: "firstBatch", Value: batch},
+	}}}, nil
+}
+
+func (s *Server) drop(database string, command Document) (Document, error) {
+	collection, err := collectionName(command)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.db.DropCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, newError(codeNamespaceNotFound, "ns not found")
+	}
+
+	return Document{
+		{Key: "ns", Value: database + "." + collection},
+		{Key: "nIndexesWas", Value: int32(1)},
+	}, nil
+}
+
+func cursorReply(id int64, namespace, batchName string, batch []interface{}) Document {
+	return Document{{Key: "cursor", Value: Document{
+		{Key: batchName, Value: batch},
+		{Key: "id", Value: id},
+		{Key: "ns", Value: namespace},
+	}}}
+}
+
+// serverCursor - open find cursor kept between getMore commands
+type serverCursor struct {
+	mutex      sync.Mutex
+	namespace  string
+	collection string
+	primaryKey string
+	cursor     *memj.Cursor
+	exhausted  bool
+
+	// lastUsed - time of find or last getMore, guarded by mutex of registry
+	lastUsed time.Time
+}
+
+// nextBatch - up to size documents without the primary key field of store
+func (c *serverCursor) nextBatch(size int64) ([]interface{}, error) {
+	batch := []interface{}{}
+	for int64(len(batch)) < size {
+		if !c.cursor.Next() {
+			c.exhausted = true
+			return batch, c.cursor.Err()
+		}
+
+		document := make(map[string]interface{}, len(c.cursor.Document()))
+		for k, v := range c.cursor.Document() {
+			if k != c.primaryKey {
+				document[k] = v
+			}
+		}
+		batch = append(batch, document)
+	}
+
+	return batch, nil
+}
+
+// cursorRegistry - open cursors by id, cursors idle for timeout are closed
+// so cursors abandoned by clients do not stay in memory
+type cursorRegistry struct {
+	mutex   sync.Mutex
+	cursors map[int64]*serverCursor
+	timeout time.Duration
+	// timer - sweeps idle cursors while there are any
+	timer *time.Timer
+}
+
+func (r *cursorRegistry) add(c *serverCursor) int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.expire()
+	c.lastUsed = time.Now()
+	if r.timer == nil {
+		r.timer = time.AfterFunc(r.timeout, r.sweep)
+	}
+
+	for {
+		var raw [8]byte
+		rand.Read(raw[:])
+		id := int64(binary.LittleEndian.Uint64(raw[:]) & math.MaxInt64)
+		if id != 0 && r.cursors[id] == nil {
+			r.cursors[id] = c
+			return id
+		}
+	}
+}
+
+func (r *cursorRegistry) get(id int64) *serverCursor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.expire()
+	c := r.cursors[id]
+	if c != nil {
+		c.lastUsed = time.Now()
+	}
+	return c
+}
+
+func (r *cursorRegistry) remove(id int64) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c, ok := r.cursors[id]
+	if ok {
+		r.close(id, c)
+	}
+	return ok
+}
+
+// closeAll - close all cursors and stop sweeping
+func (r *cursorRegistry) closeAll() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, c := range r.cursors {
+		r.close(id, c)
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// sweep - close idle cursors, runs again after timeout while cursors remain
+func (r *cursorRegistry) sweep() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.expire()
+	if r.timer == nil {
+		// stopped by closeAll
+		return
+	}
+	if len(r.cursors) > 0 {
+		r.timer.Reset(r.timeout)
+	} else {
+		r.timer = nil
+	}
+}
+
+// expire - close cursors not used for timeout, called with mutex locked
+func (r *cursorRegistry) expire() {
+	now := time.Now()
+	for id, c := range r.cursors {
+		if now.Sub(c.lastUsed) >= r.timeout {
+			r.close(id, c)
+		}
+	}
+}
+
+// close - close cursor and forget it, called with mutex locked
+func (r *cursorRegistry) close(id int64, c *serverCursor) {
+	c.mutex.Lock()
+	c.cursor.Close()
+	c.mutex.Unlock()
+	delete(r.cursors, id)
+}
+
+var objectIDCounter atomic.Uint32
+var objectIDProcess = func() [5]byte {
+	var b [5]byte
+	rand.Read(b[:])
+	return b
+}()
+
+// newObjectID - timestamp, per process random value and counter as in MongoDB
+func newObjectID() ObjectID {
+	var id ObjectID
+	binary.BigEndian.PutUint32(id[0:], uint32(time.Now().Unix()))
+	copy(id[4:9], objectIDProcess[:])
+	counter := objectIDCounter.Add(1)
+	id[9] = byte(counter >> 16)
+	id[10] = byte(counter >> 8)
+	id[11] = byte(counter)
+	return id
+}
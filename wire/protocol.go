@@ -0,0 +1,209 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Wire protocol op codes
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// OP_MSG flag bits
+const (
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+)
+
+// maxMessageSize - largest accepted message, matches maxMessageSizeBytes of hello
+const maxMessageSize = 48000000
+
+type header struct {
+	length     int32
+	requestID  int32
+	responseTo int32
+	opCode     int32
+}
+
+// message - decoded request carrying command document
+type message struct {
+	header   header
+	flags    uint32
+	command  Document
+	legacy   bool
+	database string
+}
+
+func readMessage(r io.Reader) (*message, error) {
+	var raw [16]byte
+	_, err := io.ReadFull(r, raw[:])
+	if err != nil {
+		return nil, err
+	}
+
+	h := header{
+		length:     int32(binary.LittleEndian.Uint32(raw[0:])),
+		requestID:  int32(binary.LittleEndian.Uint32(raw[4:])),
+		responseTo: int32(binary.LittleEndian.Uint32(raw[8:])),
+		opCode:     int32(binary.LittleEndian.Uint32(raw[12:])),
+	}
+	if h.length < 16 || h.length > maxMessageSize {
+		return nil, fmt.Errorf("Invalid message length %d", h.length)
+	}
+
+	body := make([]byte, h.length-16)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.opCode {
+	case opMsg:
+		return parseMsg(h, body)
+
+	case opQuery:
+		return parseQuery(h, body)
+	}
+
+	return nil, fmt.Errorf("Unsupported op code %d", h.opCode)
+}
+
+// parseMsg - OP_MSG body section becomes the command, document sequences are
+// added to it as arrays under their identifier
+func parseMsg(h header, body []byte) (*message, error) {
+	if len(body) < 5 {
+		return nil, errors.New("OP_MSG too short")
+	}
+
+	msg := &message{header: h, flags: binary.LittleEndian.Uint32(body)}
+	body = body[4:]
+	if msg.flags&flagChecksumPresent != 0 {
+		if len(body) < 4 {
+			return nil, errors.New("OP_MSG checksum missing")
+		}
+		body = body[:len(body)-4]
+	}
+
+	var sequences []Element
+	for len(body) > 0 {
+		kind := body[0]
+		body = body[1:]
+
+		switch kind {
+		case 0:
+			doc, rest, err := decodeDocument(body)
+			if err != nil {
+				return nil, err
+			}
+			msg.command = doc
+			body = rest
+
+		case 1:
+			if len(body) < 4 {
+				return nil, errors.New("OP_MSG document sequence truncated")
+			}
+			size := int(int32(binary.LittleEndian.Uint32(body)))
+			if size < 4 || size > len(body) {
+				return nil, errors.New("Invalid OP_MSG document sequence size")
+			}
+			identifier, section, err := readCString(body[4:size])
+			if err != nil {
+				return nil, err
+			}
+
+			var docs []interface{}
+			for len(section) > 0 {
+				var doc Document
+				doc, section, err = decodeDocument(section)
+				if err != nil {
+					return nil, err
+				}
+				docs = append(docs, doc)
+			}
+			sequences = append(sequences, Element{Key: identifier, Value: docs})
+			body = body[size:]
+
+		default:
+			return nil, fmt.Errorf("Unsupported OP_MSG section kind %d", kind)
+		}
+	}
+
+	if msg.command == nil {
+		return nil, errors.New("OP_MSG without body section")
+	}
+	msg.command = append(msg.command, sequences...)
+	msg.database, _ = msg.command.Lookup("$db").(string)
+
+	return msg, nil
+}
+
+// parseQuery - legacy OP_QUERY is only accepted for commands, drivers use it
+// for the initial handshake
+func parseQuery(h header, body []byte) (*message, error) {
+	if len(body) < 4 {
+		return nil, errors.New("OP_QUERY too short")
+	}
+
+	namespace, rest, err := readCString(body[4:])
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 8 {
+		return nil, errors.New("OP_QUERY truncated")
+	}
+
+	command, _, err := decodeDocument(rest[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	database := namespace
+	for i := range namespace {
+		if namespace[i] == '.' {
+			database = namespace[:i]
+			break
+		}
+	}
+
+	if len(command) > 0 && (command[0].Key == "$query" || command[0].Key == "query") {
+		if wrapped, ok := command[0].Value.(Document); ok {
+			command = wrapped
+		}
+	}
+
+	return &message{header: h, command: command, legacy: true, database: database}, nil
+}
+
+// writeReply - reply to message with document, as OP_REPLY for legacy requests
+func writeReply(w io.Writer, requestID int32, msg *message, reply Document) error {
+	doc, err := encodeDocument(reply)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	var opCode int32
+	if msg.legacy {
+		opCode = opReply
+		body = make([]byte, 20, 20+len(doc))
+		binary.LittleEndian.PutUint32(body[16:], 1)
+	} else {
+		opCode = opMsg
+		body = make([]byte, 5, 5+len(doc))
+	}
+	body = append(body, doc...)
+
+	var raw [16]byte
+	binary.LittleEndian.PutUint32(raw[0:], uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(raw[4:], uint32(requestID))
+	binary.LittleEndian.PutUint32(raw[8:], uint32(msg.header.requestID))
+	binary.LittleEndian.PutUint32(raw[12:], uint32(opCode))
+
+	_, err = w.Write(append(raw[:], body...))
+	return err
+}
@@ -0,0 +1,171 @@
+// Package wire serves a MemJ store over the MongoDB wire protocol so tests can
+// point a real MongoDB driver at it instead of a database container.
+//
+// OP_MSG is supported for the commands hello, isMaster, ping, buildInfo,
+// insert, find, getMore, killCursors, update, delete, listCollections, drop and
+// endSessions.  Legacy OP_QUERY is accepted for commands so drivers can
+// perform their initial handshake.  Collections are shared between all
+// databases, the database name of a command is only used in namespaces.
+// Cursors are closed after ten minutes without getMore, as in MongoDB.
+// Documents keep the _id sent by the driver, documents without _id get a new
+// ObjectID.  The _id is stored as an ordinary field next to the primary key of
+// the store, so New rejects stores using _id as their primary key.
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/robjsliwa/memj"
+)
+
+// Server - MongoDB wire protocol server backed by MemJ
+type Server struct {
+	db *memj.MemJ
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]bool
+	closed    bool
+	wg        sync.WaitGroup
+
+	cursors      cursorRegistry
+	connectionID atomic.Int32
+	requestID    atomic.Int32
+
+	// idLocks - locks of collections making check for duplicate _id and
+	// insert atomic, guarded by mutex
+	idLocks map[string]*sync.Mutex
+}
+
+// ErrServerClosed - returned by Serve after Close
+var ErrServerClosed = errors.New("Server closed")
+
+// New - create server for db, db must use primary key other than _id
+func New(db *memj.MemJ) (*Server, error) {
+	if db.PrimaryKey() == "_id" {
+		return nil, errors.New("Store must use a primary key other than _id")
+	}
+
+	return &Server{
+		db:        db,
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[net.Conn]bool),
+		cursors:   cursorRegistry{cursors: make(map[int64]*serverCursor), timeout: cursorTimeout},
+		idLocks:   make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// lockIDs - lock _id of documents in collection, returns unlock function
+func (s *Server) lockIDs(collection string) func() {
+	s.mutex.Lock()
+	lock, ok := s.idLocks[collection]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.idLocks[collection] = lock
+	}
+	s.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// ListenAndServe - listen on TCP address and serve connections
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve - accept and serve connections from listener until Close
+func (s *Server) Serve(l net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = true
+	s.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mutex.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mutex.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Close - stop listeners, close connections, wait for them to finish and close
+// open cursors
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	s.cursors.closeAll()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+		s.wg.Done()
+	}()
+
+	connectionID := s.connectionID.Add(1)
+	reader := bufio.NewReader(conn)
+
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			return
+		}
+
+		reply := s.handleCommand(connectionID, msg)
+		if msg.flags&flagMoreToCome != 0 {
+			continue
+		}
+
+		err = writeReply(conn, s.requestID.Add(1), msg, reply)
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robjsliwa/memj"
+)
+
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	id     int32
+}
+
+func startServer(t *testing.T) (*memj.MemJ, *testClient) {
+	db, _ := memj.New()
+	server, err := New(db)
+	if err != nil {
+		t.Fatal("Error creating server: ", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Error listening: ", err)
+	}
+	go server.Serve(l)
+	t.Cleanup(func() { server.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal("Error connecting: ", err)
+	}
+
+	return db, &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// run - send command as OP_MSG and return reply body
+func (c *testClient) run(command Document) Document {
+	body, err := encodeDocument(append(command, Element{Key: "$db", Value: "test"}))
+	if err != nil {
+		c.t.Fatal("Error encoding command: ", err)
+	}
+
+	c.id++
+	msg := make([]byte, 21, 21+len(body))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(21+len(body)))
+	binary.LittleEndian.PutUint32(msg[4:], uint32(c.id))
+	binary.LittleEndian.PutUint32(msg[12:], opMsg)
+	msg = append(msg, body...)
+
+	_, err = c.conn.Write(msg)
+	if err != nil {
+		c.t.Fatal("Error sending command: ", err)
+	}
+
+	reply, err := readMessage(c.reader)
+	if err != nil {
+		c.t.Fatal("Error reading reply: ", err)
+	}
+
+	if reply.header.responseTo != c.id {
+		c.t.Fatal("Reply to wrong request")
+	}
+
+	return reply.command
+}
+
+func isOK(reply Document) bool {
+	return reply.Lookup("ok") == 1.0
+}
+
+func TestWireRejectsIDPrimaryKey(t *testing.T) {
+	db, _ := memj.New(memj.WithPrimaryKey("_id"))
+	if _, err := New(db); err == nil {
+		t.Error("Expected error for store with _id primary key")
+		return
+	}
+}
+
+func TestWireHello(t *testing.T) {
+	_, client := startServer(t)
+
+	reply := client.run(Document{{Key: "hello", Value: int32(1)}})
+
+	if !isOK(reply) || reply.Lookup("isWritablePrimary") != true || reply.Lookup("maxWireVersion") != int32(17) {
+		t.Error("Unexpected hello reply: ", reply)
+		return
+	}
+}
+
+func TestWireInsertFindGetMore(t *testing.T) {
+	db, client := startServer(t)
+
+	var documents []interface{}
+	for i := 0; i < 10; i++ {
+		documents = append(documents, Document{{Key: "n", Value: int32(i)}})
+	}
+
+	reply := client.run(Document{{Key: "insert", Value: "items"}, {Key: "documents", Value: documents}})
+
+	if !isOK(reply) || reply.Lookup("n") != int32(10) {
+		t.Error("Unexpected insert reply: ", reply)
+		return
+	}
+
+	count, _ := db.Count("items", nil)
+	if count != 10 {
+		t.Error("Documents not stored")
+		return
+	}
+
+	reply = client.run(Document{
+		{Key: "find", Value: "items"},
+		{Key: "filter", Value: Document{{Key: "n", Value: Document{{Key: "$gte", Value: int32(3)}}}}},
+		{Key: "batchSize", Value: int32(4)},
+	})
+
+	cursor, _ := reply.Lookup("cursor").(Document)
+	firstBatch, _ := cursor.Lookup("firstBatch").([]interface{})
+	cursorID, _ := cursor.Lookup("id").(int64)
+	if !isOK(reply) || len(firstBatch) != 4 || cursorID == 0 {
+		t.Error("Unexpected find reply: ", reply)
+		return
+	}
+
+	first := firstBatch[0].(Document)
+	if _, ok := first.Lookup("_id").(ObjectID); !ok || first.Lookup("objectid") != nil {
+		t.Error("Unexpected document: ", first)
+		return
+	}
+
+	reply = client.run(Document{{Key: "getMore", Value: cursorID}, {Key: "collection", Value: "items"}})
+
+	cursor, _ = reply.Lookup("cursor").(Document)
+	nextBatch, _ := cursor.Lookup("nextBatch").([]interface{})
+	if !isOK(reply) || len(nextBatch) != 3 || cursor.Lookup("id") != int64(0) {
+		t.Error("Unexpected getMore reply: ", reply)
+		return
+	}
+
+	reply = client.run(Document{{Key: "getMore", Value: cursorID}, {Key: "collection", Value: "items"}})
+
+	if isOK(reply) || reply.Lookup("code") != int32(codeCursorNotFound) {
+		t.Error("Expected cursor not found: ", reply)
+		return
+	}
+}
+
+func TestWireUpdateDelete(t *testing.T) {
+	db, client := startServer(t)
+
+	client.run(Document{{Key: "insert", Value: "items"}, {Key: "documents", Value: []interface{}{
+		Document{{Key: "_id", Value: "a"}, {Key: "n", Value: int32(1)}},
+		Document{{Key: "_id", Value: "b"}, {Key: "n", Value: int32(2)}},
+	}}})
+
+	reply := client.run(Document{{Key: "update", Value: "items"}, {Key: "updates", Value: []interface{}{
+		Document{
+			{Key: "q", Value: Document{}},
+			{Key: "u", Value: Document{{Key: "$set", Value: Document{{Key: "flag", Value: true}}}}},
+			{Key: "multi", Value: true},
+		},
+		Document{
+			{Key: "q", Value: Document{{Key: "_id", Value: "c"}}},
+			{Key: "u", Value: Document{{Key: "$set", Value: Document{{Key: "n", Value: int32(3)}}}}},
+			{Key: "upsert", Value: true},
+		},
+	}}})
+
+	if !isOK(reply) || reply.Lookup("n") != int32(3) || reply.Lookup("nModified") != int32(2) || reply.Lookup("upserted") == nil {
+		t.Error("Unexpected update reply: ", reply)
+		return
+	}
+
+	count, _ := db.Count("items", map[string]interface{}{"flag": true})
+	if count != 2 {
+		t.Error("Documents not updated")
+		return
+	}
+
+	reply = client.run(Document{{Key: "delete", Value: "items"}, {Key: "deletes", Value: []interface{}{
+		Document{{Key: "q", Value: Document{{Key: "n", Value: Document{{Key: "$lte", Value: int32(2)}}}}}, {Key: "limit", Value: int32(0)}},
+	}}})
+
+	if !isOK(reply) || reply.Lookup("n") != int32(2) {
+		t.Error("Unexpected delete reply: ", reply)
+		return
+	}
+
+	reply = client.run(Document{{Key: "insert", Value: "items"}, {Key: "documents", Value: []interface{}{
+		Document{{Key: "_id", Value: "c"}},
+	}}})
+
+	writeErrors, _ := reply.Lookup("writeErrors").([]interface{})
+	if len(writeErrors) != 1 || writeErrors[0].(Document).Lookup("code") != int32(codeDuplicateKey) {
+		t.Error("Expected duplicate key error: ", reply)
+		return
+	}
+}
+
+func TestWireDeleteOrdered(t *testing.T) {
+	deletes := []interface{}{
+		Document{{Key: "q", Value: Document{{Key: "$and", Value: "invalid"}}}, {Key: "limit", Value: int32(0)}},
+		Document{{Key: "q", Value: Document{{Key: "_id", Value: "a"}}}, {Key: "limit", Value: int32(0)}},
+	}
+	for _, ordered := range []bool{true, false} {
+		db, _ := memj.New()
+		server, _ := New(db)
+		db.InsertJSON("items", `{"_id": "a"}`)
+		reply, err := server.delete(Document{{Key: "delete", Value: "items"}, {Key: "deletes", Value: deletes}, {Key: "ordered", Value: ordered}})
+		writeErrors, _ := reply.Lookup("writeErrors").([]interface{})
+
+		expected := int32(1)
+		if ordered {
+			expected = 0
+		}
+		if err != nil || len(writeErrors) != 1 || reply.Lookup("n") != expected {
+			t.Error("Unexpected delete reply with ordered ", ordered, ": ", reply, err)
+			return
+		}
+	}
+}
+
+func TestWireCursorTimeout(t *testing.T) {
+	db, _ := memj.New()
+	server, _ := New(db)
+	server.cursors.timeout = 10 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		db.Insert("items", map[string]interface{}{"n": i})
+	}
+
+	find := Document{{Key: "find", Value: "items"}, {Key: "batchSize", Value: int32(1)}}
+	reply, _ := server.find("test", find)
+	cursorID, _ := reply.Lookup("cursor").(Document).Lookup("id").(int64)
+	if cursorID == 0 {
+		t.Error("Expected open cursor: ", reply)
+		return
+	}
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		server.cursors.mutex.Lock()
+		open := len(server.cursors.cursors)
+		server.cursors.mutex.Unlock()
+		if open == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Error("Idle cursor not closed")
+			return
+		}
+	}
+
+	_, err := server.getMore("test", Document{{Key: "getMore", Value: cursorID}, {Key: "collection", Value: "items"}})
+	var commandErr *commandError
+	if !errors.As(err, &commandErr) || commandErr.code != codeCursorNotFound {
+		t.Error("Expected cursor not found, got ", err)
+		return
+	}
+
+	server.find("test", find)
+	server.Close()
+	if len(server.cursors.cursors) != 0 || server.cursors.timer != nil {
+		t.Error("Expected Close to close cursors")
+		return
+	}
+}
+
+func TestWireConcurrentInsertDuplicateID(t *testing.T) {
+	db, _ := memj.New()
+	server, _ := New(db)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	inserted := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, _ := server.insert(Document{{Key: "insert", Value: "items"}, {Key: "documents", Value: []interface{}{
+				Document{{Key: "_id", Value: "a"}},
+			}}})
+			mutex.Lock()
+			inserted += int(reply.Lookup("n").(int32))
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if count, _ := db.Count("items", nil); inserted != 1 || count != 1 {
+		t.Error("Expected single insert of _id, got ", inserted, " inserts and ", count, " documents")
+		return
+	}
+}
+
+func TestWireListAndDropCollections(t *testing.T) {
+	_, client := startServer(t)
+
+	client.run(Document{{Key: "insert", Value: "items"}, {Key: "documents", Value: []interface{}{Document{}}}})
+
+	reply := client.run(Document{{Key: "listCollections", Value: int32(1)}})
+
+	cursor, _ := reply.Lookup("cursor").(Document)
+	batch, _ := cursor.Lookup("firstBatch").([]interface{})
+	if !isOK(reply) || len(batch) != 1 || batch[0].(Document).Lookup("name") != "items" {
+		t.Error("Unexpected listCollections reply: ", reply)
+		return
+	}
+
+	reply = client.run(Document{{Key: "drop", Value: "items"}})
+
+	if !isOK(reply) {
+		t.Error("Unexpected drop reply: ", reply)
+		return
+	}
+
+	reply = client.run(Document{{Key: "drop", Value: "items"}})
+
+	if isOK(reply) || reply.Lookup("code") != int32(codeNamespaceNotFound) {
+		t.Error("Expected namespace not found: ", reply)
+		return
+	}
+}
+
+func TestWireUnknownCommand(t *testing.T) {
+	_, client := startServer(t)
+
+	reply := client.run(Document{{Key: "mapReduce", Value: "items"}})
+
+	if isOK(reply) || reply.Lookup("code") != int32(codeCommandNotFound) || reply.Lookup("codeName") != "CommandNotFound" {
+		t.Error("Expected command not found: ", reply)
+		return
+	}
+}
+
+func TestWireLegacyHandshake(t *testing.T) {
+	_, client := startServer(t)
+
+	body, _ := encodeDocument(Document{{Key: "isMaster", Value: int32(1)}})
+	namespace := "admin.$cmd\x00"
+	length := 16 + 4 + len(namespace) + 8 + len(body)
+
+	msg := make([]byte, 20, length)
+	binary.LittleEndian.PutUint32(msg[0:], uint32(length))
+	binary.LittleEndian.PutUint32(msg[4:], 7)
+	binary.LittleEndian.PutUint32(msg[12:], opQuery)
+	msg = append(msg, namespace...)
+	msg = append(msg, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff)
+	msg = append(msg, body...)
+
+	_, err := client.conn.Write(msg)
+	if err != nil {
+		t.Error("Error sending query: ", err)
+		return
+	}
+
+	var raw [36]byte
+	_, err = io.ReadFull(client.reader, raw[:])
+	if err != nil {
+		t.Error("Error reading reply: ", err)
+		return
+	}
+
+	if binary.LittleEndian.Uint32(raw[12:]) != opReply || binary.LittleEndian.Uint32(raw[8:]) != 7 || binary.LittleEndian.Uint32(raw[32:]) != 1 {
+		t.Error("Unexpected reply header")
+		return
+	}
+
+	size := binary.LittleEndian.Uint32(raw[0:]) - 36
+	rest := make([]byte, size)
+	io.ReadFull(client.reader, rest)
+
+	reply, _, err := decodeDocument(rest)
+	if err != nil || !isOK(reply) || reply.Lookup("ismaster") != true {
+		t.Error("Unexpected isMaster reply: ", reply, err)
+		return
+	}
+}